@@ -0,0 +1,367 @@
+package rikka
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// ArgType is the kind of value an argument is converted to.
+type ArgType int
+
+const (
+	// ArgString is a single word.
+	ArgString ArgType = iota
+	// ArgUser is a user mention or id, converted to a *disgord.User.
+	ArgUser
+	// ArgMember is a user mention or id, converted to a *disgord.Member of
+	// the current guild.
+	ArgMember
+	// ArgChannel is a channel mention or id, converted to a
+	// *disgord.Channel.
+	ArgChannel
+	// ArgRole is a role mention, id or name, converted to a *disgord.Role
+	// of the current guild.
+	ArgRole
+	// ArgSnowflake is any mention or id, converted to a disgord.Snowflake.
+	ArgSnowflake
+	// ArgInt is an integer, converted to an int64.
+	ArgInt
+	// ArgDuration is a duration such as "2h30m" or "3d", converted to a
+	// time.Duration.
+	ArgDuration
+	// ArgEnum is one of Arg.Choices, matched ignoring case.
+	ArgEnum
+	// ArgRest is the remainder of the message.
+	ArgRest
+)
+
+// Arg describes a single command argument.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	// Choices are the accepted values of an ArgEnum.
+	Choices []string
+}
+
+func (a Arg) usage() string {
+	name := a.Name
+	switch a.Type {
+	case ArgEnum:
+		name = strings.Join(a.Choices, " | ")
+	case ArgRest:
+		name += "..."
+	}
+
+	if a.Optional {
+		return "[" + name + "]"
+	}
+
+	return "<" + name + ">"
+}
+
+// ArgSpec declares the arguments a command accepts, in order.
+type ArgSpec []Arg
+
+// Usage generates a usage string from the spec, e.g. "<user> [duration]".
+func (spec ArgSpec) Usage() string {
+	usages := make([]string, len(spec))
+	for i, e := range spec {
+		usages[i] = e.usage()
+	}
+
+	return strings.Join(usages, " ")
+}
+
+// ArgError is returned when an argument is missing or cannot be converted.
+type ArgError struct {
+	Arg   Arg
+	Input string
+	Err   error
+}
+
+func (e *ArgError) Error() string {
+	if e.Input == "" {
+		return fmt.Sprintf("Missing argument `%s`", e.Arg.usage())
+	}
+
+	return fmt.Sprintf("Invalid argument `%s` for `%s`: %s", e.Input, e.Arg.usage(), e.Err)
+}
+
+func (e *ArgError) Unwrap() error {
+	return e.Err
+}
+
+// ArgValues holds converted arguments by name. Accessors return the zero value
+// for arguments that were optional and not supplied.
+type ArgValues map[string]interface{}
+
+func (v ArgValues) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+func (v ArgValues) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+func (v ArgValues) User(name string) *disgord.User {
+	u, _ := v[name].(*disgord.User)
+	return u
+}
+
+func (v ArgValues) Member(name string) *disgord.Member {
+	m, _ := v[name].(*disgord.Member)
+	return m
+}
+
+func (v ArgValues) Channel(name string) *disgord.Channel {
+	c, _ := v[name].(*disgord.Channel)
+	return c
+}
+
+func (v ArgValues) Role(name string) *disgord.Role {
+	r, _ := v[name].(*disgord.Role)
+	return r
+}
+
+func (v ArgValues) Snowflake(name string) disgord.Snowflake {
+	s, _ := v[name].(disgord.Snowflake)
+	return s
+}
+
+func (v ArgValues) Int(name string) int64 {
+	i, _ := v[name].(int64)
+	return i
+}
+
+func (v ArgValues) Duration(name string) time.Duration {
+	d, _ := v[name].(time.Duration)
+	return d
+}
+
+// Parse converts args according to the spec. Guild specific arguments such as
+// members and roles are resolved in guildID.
+func (spec ArgSpec) Parse(ctx context.Context, s disgord.Session, guildID disgord.Snowflake, args Args) (ArgValues, error) {
+	vals := ArgValues{}
+
+	for _, arg := range spec {
+		if len(args) == 0 {
+			if arg.Optional {
+				continue
+			}
+
+			return nil, &ArgError{Arg: arg}
+		}
+
+		var input string
+		if arg.Type == ArgRest {
+			input = args.Rest()
+			args = nil
+		} else {
+			input = args.Pop()
+		}
+
+		val, err := convertArg(ctx, s, guildID, arg, input)
+		if err != nil {
+			return nil, &ArgError{Arg: arg, Input: input, Err: err}
+		}
+
+		vals[arg.Name] = val
+	}
+
+	if len(args) > 0 {
+		return nil, xerrors.Errorf("Too many arguments, unexpected `%s`", args.Rest())
+	}
+
+	return vals, nil
+}
+
+func convertArg(ctx context.Context, s disgord.Session, guildID disgord.Snowflake, arg Arg, input string) (interface{}, error) {
+	switch arg.Type {
+	case ArgString, ArgRest:
+		return input, nil
+
+	case ArgUser:
+		id, err := ExtractID(UserMentionRegex, input)
+		if err != nil {
+			return nil, xerrors.New("not a user mention or id")
+		}
+
+		user, err := s.GetUser(ctx, id)
+		if err != nil {
+			return nil, xerrors.New("user not found")
+		}
+
+		return user, nil
+
+	case ArgMember:
+		if guildID.IsZero() {
+			return nil, xerrors.New("members can only be used in servers")
+		}
+
+		id, err := ExtractID(UserMentionRegex, input)
+		if err != nil {
+			return nil, xerrors.New("not a user mention or id")
+		}
+
+		member, err := s.GetMember(ctx, guildID, id)
+		if err != nil {
+			return nil, xerrors.New("member not found in this server")
+		}
+
+		return member, nil
+
+	case ArgChannel:
+		id, err := ExtractID(ChannelMentionRegex, input)
+		if err != nil {
+			return nil, xerrors.New("not a channel mention or id")
+		}
+
+		channel, err := s.GetChannel(ctx, id)
+		if err != nil {
+			return nil, xerrors.New("channel not found")
+		}
+
+		if !guildID.IsZero() && channel.GuildID != guildID {
+			return nil, xerrors.New("channel is not in this server")
+		}
+
+		return channel, nil
+
+	case ArgRole:
+		if guildID.IsZero() {
+			return nil, xerrors.New("roles can only be used in servers")
+		}
+
+		roles, err := s.GetGuildRoles(ctx, guildID)
+		if err != nil {
+			return nil, xerrors.New("failed to load server roles")
+		}
+
+		id, _ := ExtractID(RoleMentionRegex, input)
+		for _, e := range roles {
+			if e.ID == id || strings.EqualFold(e.Name, input) {
+				return e, nil
+			}
+		}
+
+		return nil, xerrors.New("role not found")
+
+	case ArgSnowflake:
+		for _, reg := range []*regexp.Regexp{UserMentionRegex, ChannelMentionRegex, RoleMentionRegex} {
+			if reg.MatchString(input) {
+				return ExtractID(reg, input)
+			}
+		}
+
+		id, err := ExtractID(UserMentionRegex, input)
+		if err != nil {
+			return nil, xerrors.New("not a mention or id")
+		}
+
+		return id, nil
+
+	case ArgInt:
+		i, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, xerrors.New("not a whole number")
+		}
+
+		return i, nil
+
+	case ArgDuration:
+		return ParseDuration(input)
+
+	case ArgEnum:
+		for _, e := range arg.Choices {
+			if strings.EqualFold(e, input) {
+				return e, nil
+			}
+		}
+
+		return nil, xerrors.Errorf("must be one of [%s]", strings.Join(arg.Choices, ", "))
+
+	default:
+		panic("unknown arg type: " + strconv.FormatInt(int64(arg.Type), 10))
+	}
+}
+
+var durationUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration made of whole numbers followed by one of the
+// units s, m, h, d or w, such as "2h30m" or "3d".
+func ParseDuration(s string) (time.Duration, error) {
+	var (
+		orig  = s
+		total time.Duration
+	)
+
+	s = strings.ToLower(s)
+	if s == "" {
+		return 0, xerrors.New("empty duration")
+	}
+
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, xerrors.Errorf("invalid duration %q, expected something like 2h30m or 3d", orig)
+		}
+
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, xerrors.Errorf("invalid duration %q: %w", orig, err)
+		}
+
+		unit, ok := durationUnits[s[i]]
+		if !ok {
+			return 0, xerrors.Errorf("unknown unit %q in duration %q, expected one of s, m, h, d or w", s[i], orig)
+		}
+
+		d := time.Duration(n) * unit
+		if d/unit != time.Duration(n) || total+d < total {
+			return 0, xerrors.Errorf("duration %q is too long", orig)
+		}
+
+		total += d
+		s = s[i+1:]
+	}
+
+	return total, nil
+}
+
+// ParseArgs converts args according to spec. If they are invalid an error
+// along with the command's usage is sent to the channel and ok is false.
+// name is the full command path used in the usage line, e.g. "log messages".
+func (r *Rikka) ParseArgs(s disgord.Session, mc *disgord.MessageCreate, name string, spec ArgSpec, args Args) (vals ArgValues, ok bool) {
+	vals, err := spec.Parse(mc.Ctx, s, mc.Message.GuildID, args)
+	if err != nil {
+		prefix := r.Prefixes(mc.Message.GuildID)[0]
+		err = &UserError{
+			Kind:    ErrorKindBadInput,
+			Message: fmt.Sprintf("%s\nUsage: `%s%s %s`", err, prefix, name, spec.Usage()),
+			Err:     err,
+		}
+		r.HandleError(mc.Ctx, s, mc.Message, err, "Invalid arguments")
+		return nil, false
+	}
+
+	return vals, true
+}
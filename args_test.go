@@ -0,0 +1,89 @@
+package rikka
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "2h30m", want: 2*time.Hour + 30*time.Minute},
+		{in: "3d", want: 72 * time.Hour},
+		{in: "1W1D", want: 8 * 24 * time.Hour},
+		{in: "90s", want: 90 * time.Second},
+		{in: "", err: true},
+		{in: "10", err: true},
+		{in: "h", err: true},
+		{in: "5y", err: true},
+		{in: "99999999999999w", err: true},
+	} {
+		got, err := ParseDuration(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("ParseDuration(%q) error = %v, want error %v", tc.in, err, tc.err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestArgSpecUsage(t *testing.T) {
+	spec := ArgSpec{
+		{Name: "type", Type: ArgEnum, Choices: []string{"update", "delete"}},
+		{Name: "count", Type: ArgInt},
+		{Name: "reason", Type: ArgRest, Optional: true},
+	}
+
+	const want = "<update | delete> <count> [reason...]"
+	if got := spec.Usage(); got != want {
+		t.Errorf("Usage() = %q, want %q", got, want)
+	}
+}
+
+func TestArgSpecParse(t *testing.T) {
+	spec := ArgSpec{
+		{Name: "action", Type: ArgEnum, Choices: []string{"enable", "disable"}},
+		{Name: "count", Type: ArgInt},
+		{Name: "after", Type: ArgDuration, Optional: true},
+		{Name: "reason", Type: ArgRest, Optional: true},
+	}
+
+	vals, err := spec.Parse(context.Background(), nil, 0, Tokenize("ENABLE 5 1h too  many"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vals.String("action") != "enable" || vals.Int("count") != 5 || vals.Duration("after") != time.Hour || vals.String("reason") != "too  many" {
+		t.Errorf("unexpected values: %v", vals)
+	}
+
+	vals, err = spec.Parse(context.Background(), nil, 0, Tokenize("disable 1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vals.Has("after") || vals.Has("reason") {
+		t.Errorf("unexpected optional values: %v", vals)
+	}
+
+	for _, args := range []string{
+		"",
+		"toggle 1",
+		"enable one",
+		"enable 1 soon",
+	} {
+		if _, err := spec.Parse(context.Background(), nil, 0, Tokenize(args)); err == nil {
+			t.Errorf("Parse(%q) expected error", args)
+		}
+	}
+
+	_, err = ArgSpec{{Name: "id", Type: ArgSnowflake}}.Parse(context.Background(), nil, 0, Tokenize("1 2"))
+	if err == nil {
+		t.Error("expected too many arguments error")
+	}
+}
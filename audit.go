@@ -0,0 +1,399 @@
+package rikka
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+// DefaultAuditRetention is how long invocations are kept in the audit log.
+const DefaultAuditRetention = 30 * 24 * time.Hour
+
+// auditPurgeJob is the scheduled job deleting entries older than the
+// retention.
+const (
+	auditPurgeJob      = "audit_purge"
+	auditPurgeSchedule = "@hourly"
+)
+
+const (
+	// maxAuditArgs is the longest arguments stored in the audit log.
+	maxAuditArgs = 200
+	// maxAuditScan is the most entries a single audit log query reads, so
+	// queries with filters that rarely match stay cheap.
+	maxAuditScan = 5000
+)
+
+// Outcomes of commands recorded in the audit log, in addition to the kinds
+// of UserError.
+const (
+	AuditOutcomeOK = "ok"
+	// AuditOutcomeBlocked is recorded for commands that were stopped by a
+	// middleware or cooldown before running.
+	AuditOutcomeBlocked  = "blocked"
+	AuditOutcomeInternal = "internal"
+	AuditOutcomePanic    = "panic"
+)
+
+// auditLog stores the commands run in each guild, keyed by guild and time.
+type auditLog struct {
+	dir storage.Subspace
+}
+
+// AuditEntry is an invocation of a command recorded in the audit log.
+type AuditEntry struct {
+	At        time.Time
+	GuildID   disgord.Snowflake
+	ChannelID disgord.Snowflake
+	UserID    disgord.Snowflake
+	MessageID disgord.Snowflake
+	// Command is the command name followed by any subcommands, e.g. "log
+	// messages delete enable".
+	Command string
+	Args    string
+	Outcome string
+	Latency time.Duration
+}
+
+// auditRecord is an AuditEntry as stored. The guild and time are part of the
+// key.
+type auditRecord struct {
+	ChannelID uint64
+	UserID    uint64
+	Command   string
+	Args      string `json:",omitempty"`
+	Outcome   string
+	Latency   time.Duration
+}
+
+// AuditFilter selects entries from the audit log.
+type AuditFilter struct {
+	// UserID only matches invocations by a user if set.
+	UserID disgord.Snowflake
+	// Command only matches invocations of a command or its subcommands if
+	// set, e.g. "log" matches "log messages delete enable".
+	Command string
+	// Before only matches invocations before a time if set.
+	Before time.Time
+	// Limit is the maximum amount of entries returned.
+	Limit int
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	if !f.UserID.IsZero() && e.UserID != f.UserID {
+		return false
+	}
+	if f.Command != "" && e.Command != f.Command && !strings.HasPrefix(e.Command, f.Command+" ") {
+		return false
+	}
+
+	return true
+}
+
+// auditInvocation collects the audit entry of a command while it runs.
+type auditInvocation struct {
+	mu    sync.Mutex
+	entry AuditEntry
+	start time.Time
+	// ran and returned are set when the command's Handle func is called and
+	// returns, and outcome when it reports an error.
+	ran, returned bool
+	outcome       string
+	// hideArgs is set for owner commands, whose arguments may be code or
+	// secrets.
+	hideArgs bool
+}
+
+type auditKey struct{}
+
+// startAudit begins the audit entry of the command described by help handling
+// mc, and returns a context carrying it. The arguments of owner commands
+// aren't recorded.
+func startAudit(mc *disgord.MessageCreate, help CommandHelp, args Args) (context.Context, *auditInvocation) {
+	msg := mc.Message
+	a := &auditInvocation{
+		start: time.Now(),
+		entry: AuditEntry{
+			GuildID:   msg.GuildID,
+			ChannelID: msg.ChannelID,
+			MessageID: msg.ID,
+			Command:   help.Name,
+		},
+		hideArgs: help.Section == HelpSectionOwner,
+	}
+	a.setArgs(args)
+	if msg.Author != nil {
+		a.entry.UserID = msg.Author.ID
+	}
+
+	ctx := mc.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, auditKey{}, a), a
+}
+
+func auditFromContext(ctx context.Context) *auditInvocation {
+	if ctx == nil {
+		return nil
+	}
+
+	a, _ := ctx.Value(auditKey{}).(*auditInvocation)
+	return a
+}
+
+// handle runs a command's Handle func, noting whether it returned.
+func (a *auditInvocation) handle(fn func()) {
+	a.mu.Lock()
+	a.ran = true
+	a.mu.Unlock()
+
+	fn()
+
+	a.mu.Lock()
+	a.returned = true
+	a.mu.Unlock()
+}
+
+// setAuditOutcome records the first error reported by the command ctx belongs
+// to, if any.
+func setAuditOutcome(ctx context.Context, outcome string) {
+	a := auditFromContext(ctx)
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	if a.outcome == "" {
+		a.outcome = outcome
+	}
+	a.mu.Unlock()
+}
+
+// setAuditCommand replaces the command and arguments recorded for ctx, e.g.
+// with the subcommands a Group routed to and the arguments left after them.
+func setAuditCommand(ctx context.Context, command string, args Args) {
+	a := auditFromContext(ctx)
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.entry.Command = command
+	a.setArgs(args)
+	a.mu.Unlock()
+}
+
+func (a *auditInvocation) setArgs(args Args) {
+	if a.hideArgs {
+		a.entry.Args = ""
+		return
+	}
+
+	a.entry.Args = truncate(strings.Join(args.Strings(), " "), maxAuditArgs)
+}
+
+// finishAudit stores the audit entry of a command once it has returned or
+// panicked.
+func (r *Rikka) finishAudit(a *auditInvocation) {
+	a.mu.Lock()
+	e := a.entry
+	e.At = a.start
+	e.Latency = time.Since(a.start)
+	switch {
+	case a.outcome != "":
+		e.Outcome = a.outcome
+	case !a.ran:
+		e.Outcome = AuditOutcomeBlocked
+	case !a.returned:
+		e.Outcome = AuditOutcomePanic
+	default:
+		e.Outcome = AuditOutcomeOK
+	}
+	a.mu.Unlock()
+
+	err := r.RecordAudit(e)
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to record command in audit log", slog.Error(err), slog.F("command", e.Command))
+	}
+}
+
+// RecordAudit stores an entry in the audit log.
+func (r *Rikka) RecordAudit(e AuditEntry) error {
+	raw, err := json.Marshal(auditRecord{
+		ChannelID: uint64(e.ChannelID),
+		UserID:    uint64(e.UserID),
+		Command:   e.Command,
+		Args:      e.Args,
+		Outcome:   e.Outcome,
+		Latency:   e.Latency,
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	err = r.Transact(func(t storage.Transaction) error {
+		t.Set(r.fmtAuditKey(e.GuildID, e.At, e.MessageID), raw)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// AuditLog returns the invocations in a guild matching filter, newest first.
+// Private messages are recorded under the zero guild id. Entries older than
+// AuditRetention are never returned, even if they haven't been purged yet.
+func (r *Rikka) AuditLog(guildID disgord.Snowflake, filter AuditFilter) ([]AuditEntry, error) {
+	sub := r.audit.dir.Sub(uint64(guildID))
+	rng := sub.Range()
+	if r.AuditRetention > 0 {
+		rng.Begin = sub.Pack(tuple.Tuple{time.Now().Add(-r.AuditRetention).UnixNano()})
+	}
+	if !filter.Before.IsZero() {
+		rng.End = sub.Pack(tuple.Tuple{filter.Before.UnixNano()})
+	}
+	if filter.Command != "" {
+		filter.Command = strings.ToLower(filter.Command)
+	}
+
+	var entries []AuditEntry
+	err := r.ReadTransact(func(t storage.ReadTransaction) error {
+		entries = nil
+		var (
+			snap    = t.Snapshot()
+			end     = rng.End
+			scanned int
+		)
+
+		for scanned < maxAuditScan && (filter.Limit <= 0 || len(entries) < filter.Limit) {
+			kvs, err := snap.GetRange(storage.Range{Begin: rng.Begin, End: end}, storage.RangeOptions{Limit: 100, Reverse: true})
+			if err != nil {
+				return err
+			}
+			if len(kvs) == 0 {
+				return nil
+			}
+			scanned += len(kvs)
+			end = kvs[len(kvs)-1].Key
+
+			for _, kv := range kvs {
+				e, err := r.unpackAuditEntry(guildID, kv)
+				if err != nil {
+					return err
+				}
+				if !filter.matches(e) {
+					continue
+				}
+
+				entries = append(entries, e)
+				if filter.Limit > 0 && len(entries) == filter.Limit {
+					return nil
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// purgeAudit deletes the entries of every guild older than AuditRetention,
+// one guild per transaction.
+func (r *Rikka) purgeAudit(ctx context.Context) error {
+	if r.AuditRetention <= 0 {
+		return nil
+	}
+
+	var (
+		cutoff = time.Now().Add(-r.AuditRetention).UnixNano()
+		begin  = r.audit.dir.Range().Begin
+		end    = r.audit.dir.Range().End
+	)
+	for ctx.Err() == nil {
+		var done bool
+		err := r.Transact(func(t storage.Transaction) error {
+			kvs, err := t.GetRange(storage.Range{Begin: begin, End: end}, storage.RangeOptions{Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(kvs) == 0 {
+				done = true
+				return nil
+			}
+
+			tup, err := r.audit.dir.Unpack(kvs[0].Key)
+			if err != nil {
+				return xerrors.Errorf("failed to unpack audit key: %w", err)
+			}
+			if len(tup) != 3 {
+				return xerrors.Errorf("unexpected audit key %s", kvs[0].Key)
+			}
+
+			guild := r.audit.dir.Sub(tup[0])
+			t.ClearRange(storage.Range{
+				Begin: guild.Range().Begin,
+				End:   guild.Pack(tuple.Tuple{cutoff}),
+			})
+			begin = guild.Range().End
+			return nil
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to purge audit log: %w", err)
+		}
+		if done {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (r *Rikka) unpackAuditEntry(guildID disgord.Snowflake, kv storage.KeyValue) (AuditEntry, error) {
+	tup, err := r.audit.dir.Unpack(kv.Key)
+	if err != nil {
+		return AuditEntry{}, xerrors.Errorf("failed to unpack audit key: %w", err)
+	}
+	if len(tup) != 3 {
+		return AuditEntry{}, xerrors.Errorf("unexpected audit key %s", kv.Key)
+	}
+	at, _ := tup[1].(int64)
+
+	var rec auditRecord
+	err = json.Unmarshal(kv.Value, &rec)
+	if err != nil {
+		return AuditEntry{}, xerrors.Errorf("failed to decode audit entry: %w", err)
+	}
+
+	return AuditEntry{
+		At:        time.Unix(0, at),
+		GuildID:   guildID,
+		ChannelID: disgord.Snowflake(rec.ChannelID),
+		UserID:    disgord.Snowflake(rec.UserID),
+		MessageID: tupleSnowflake(tup[2]),
+		Command:   rec.Command,
+		Args:      rec.Args,
+		Outcome:   rec.Outcome,
+		Latency:   rec.Latency,
+	}, nil
+}
+
+func (r *Rikka) fmtAuditKey(guildID disgord.Snowflake, at time.Time, messageID disgord.Snowflake) storage.Key {
+	return r.audit.dir.Pack(tuple.Tuple{uint64(guildID), at.UnixNano(), uint64(messageID)})
+}
@@ -0,0 +1,161 @@
+package rikka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/fake"
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestAuditLog(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+
+	var (
+		guild = disgord.Snowflake(10)
+		start = time.Now().Add(-time.Hour)
+	)
+	entries := []AuditEntry{
+		{UserID: 2, Command: "prefix", Args: "add !", Outcome: AuditOutcomeOK},
+		{UserID: 3, Command: "log messages delete enable", Outcome: "permission"},
+		{UserID: 2, Command: "log messages update disable", Outcome: AuditOutcomeOK},
+		{UserID: 3, Command: "logs", Outcome: AuditOutcomeOK},
+	}
+	for i, e := range entries {
+		e.GuildID = guild
+		e.ChannelID = 20
+		e.MessageID = disgord.Snowflake(100 + i)
+		e.At = start.Add(time.Duration(i) * time.Minute)
+		if err := r.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other guilds aren't included.
+	if err := r.RecordAudit(AuditEntry{GuildID: 11, At: start, Command: "prefix"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []disgord.Snowflake
+	}{
+		{"all", AuditFilter{}, []disgord.Snowflake{103, 102, 101, 100}},
+		{"limit", AuditFilter{Limit: 2}, []disgord.Snowflake{103, 102}},
+		{"user", AuditFilter{UserID: 2}, []disgord.Snowflake{102, 100}},
+		{"command", AuditFilter{Command: "log"}, []disgord.Snowflake{102, 101}},
+		{"subcommand", AuditFilter{Command: "LOG messages delete"}, []disgord.Snowflake{101}},
+		{"user and command", AuditFilter{UserID: 3, Command: "log"}, []disgord.Snowflake{101}},
+		{"before", AuditFilter{Before: start.Add(2 * time.Minute)}, []disgord.Snowflake{101, 100}},
+	}
+
+	for _, tt := range tests {
+		got, err := r.AuditLog(guild, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]disgord.Snowflake, len(got))
+		for i, e := range got {
+			ids[i] = e.MessageID
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("%s: got entries %v, want %v", tt.name, ids, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: got entries %v, want %v", tt.name, ids, tt.want)
+				break
+			}
+		}
+	}
+
+	got, err := r.AuditLog(guild, AuditFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := got[0]; e.UserID != 3 || e.ChannelID != 20 || e.Command != "logs" || !e.At.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("got entry %+v, want the last one recorded", e)
+	}
+}
+
+func TestAuditRetention(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+	r.AuditRetention = time.Hour
+
+	now := time.Now()
+	for _, guild := range []disgord.Snowflake{10, 11} {
+		for i, at := range []time.Time{now.Add(-3 * time.Hour), now.Add(-30 * time.Minute), now} {
+			err := r.RecordAudit(AuditEntry{GuildID: guild, MessageID: disgord.Snowflake(100 + i), At: at, Command: "ping"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Expired entries are hidden before they are purged.
+	got, err := r.AuditLog(10, AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].MessageID != 101 {
+		t.Errorf("got %d entries, want the 2 within the retention", len(got))
+	}
+
+	err = r.purgeAudit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	err = r.ReadTransact(func(t storage.ReadTransaction) error {
+		kvs, err := t.GetRange(r.audit.dir.Range(), storage.RangeOptions{})
+		stored = len(kvs)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 4 {
+		t.Errorf("%d entries stored after purging, want 4", stored)
+	}
+}
+
+func TestAuditOwnerArgs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+	r.RegisterCommands(
+		&testCmd{help: CommandHelp{Name: "eval", Section: HelpSectionOwner}},
+		&testCmd{help: CommandHelp{Name: "echo"}},
+	)
+
+	s := fake.NewSession(&disgord.User{ID: 1, Username: "rikka", Bot: true})
+	s.AddChannel(&disgord.Channel{ID: 20, Type: disgord.ChannelTypeDM})
+	for i, content := range []string{"r.eval token", "r.echo hello"} {
+		r.DispatchEvent(s, disgord.EvtMessageCreate, &disgord.MessageCreate{
+			Message: &disgord.Message{ID: disgord.Snowflake(100 + i), ChannelID: 20, Author: &disgord.User{ID: 2}, Content: content},
+			Ctx:     context.Background(),
+		})
+	}
+
+	got, err := r.AuditLog(0, AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]string{}
+	for _, e := range got {
+		args[e.Command] = e.Args
+	}
+	if len(args) != 2 || args["eval"] != "" || args["echo"] != "hello" {
+		t.Errorf("got arguments %v, want none for eval", args)
+	}
+}
@@ -0,0 +1,214 @@
+package rikka
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// DefaultConfirmTimeout is how long Confirm waits for an answer. It is shorter
+// than DefaultHandlerTimeout so commands have time to act on the answer.
+const DefaultConfirmTimeout = 20 * time.Second
+
+// Reactions used to answer confirmation prompts.
+const (
+	ConfirmYes = "✅"
+	ConfirmNo  = "❌"
+)
+
+// ErrAwaitTimeout is returned when nothing matching was received before the
+// timeout passed to an Await method.
+var ErrAwaitTimeout = xerrors.New("timed out waiting for a response")
+
+// awaiters are the pending calls to await.
+type awaiters struct {
+	mu      sync.Mutex
+	waiting []*awaiter
+}
+
+// awaiter receives the first event match returns true for.
+type awaiter struct {
+	r     *Rikka
+	match func(evt interface{}) bool
+	ch    chan interface{}
+}
+
+// AwaitMessage waits for the next message in a channel that match returns
+// true for. It returns ErrAwaitTimeout if none is received within timeout, or
+// the context's error if ctx is done first.
+func (r *Rikka) AwaitMessage(ctx context.Context, channelID disgord.Snowflake, timeout time.Duration, match func(msg *disgord.Message) bool) (*disgord.Message, error) {
+	evt, err := r.await(ctx, timeout, func(evt interface{}) bool {
+		mc, ok := evt.(*disgord.MessageCreate)
+		return ok && mc.Message.ChannelID == channelID && match(mc.Message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return evt.(*disgord.MessageCreate).Message, nil
+}
+
+// AwaitReaction waits for the next reaction added to a message that match
+// returns true for. It returns ErrAwaitTimeout if none is received within
+// timeout, or the context's error if ctx is done first.
+func (r *Rikka) AwaitReaction(ctx context.Context, messageID disgord.Snowflake, timeout time.Duration, match func(h *disgord.MessageReactionAdd) bool) (*disgord.MessageReactionAdd, error) {
+	evt, err := r.await(ctx, timeout, func(evt interface{}) bool {
+		h, ok := evt.(*disgord.MessageReactionAdd)
+		return ok && h.MessageID == messageID && match(h)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return evt.(*disgord.MessageReactionAdd), nil
+}
+
+// Confirm asks the author of msg a yes or no question, which they can answer
+// by reacting to it or by replying with yes or no. It returns false if they
+// answer no or don't answer within DefaultConfirmTimeout.
+func (r *Rikka) Confirm(ctx context.Context, s disgord.Session, msg *disgord.Message, question string) (bool, error) {
+	prompt, err := s.SendMsg(ctx, msg.ChannelID, question+"\nReact with "+ConfirmYes+" or "+ConfirmNo+", or reply `yes` or `no`.")
+	if err != nil {
+		return false, xerrors.Errorf("failed to send confirmation prompt: %w", err)
+	}
+
+	author := msg.Author.ID
+	answer := r.addAwaiter(func(evt interface{}) bool {
+		switch evt := evt.(type) {
+		case *disgord.MessageCreate:
+			_, ok := parseConfirmation(evt.Message.Content)
+			return ok && evt.Message.ChannelID == prompt.ChannelID && evt.Message.Author != nil && evt.Message.Author.ID == author
+		case *disgord.MessageReactionAdd:
+			return evt.MessageID == prompt.ID && evt.UserID == author && evt.PartialEmoji != nil &&
+				(evt.PartialEmoji.Name == ConfirmYes || evt.PartialEmoji.Name == ConfirmNo)
+		default:
+			return false
+		}
+	})
+
+	// Reactions are added after waiting has started, so answers sent while
+	// they are being added aren't missed.
+	for _, e := range []string{ConfirmYes, ConfirmNo} {
+		err := s.CreateReaction(ctx, prompt.ChannelID, prompt.ID, e)
+		if err != nil {
+			r.Log.Warn(ctx, "failed to add confirmation reaction", slog.Error(err), slog.F("channel_id", prompt.ChannelID))
+			break
+		}
+	}
+	evt, err := answer.wait(ctx, DefaultConfirmTimeout)
+
+	// The prompt may outlive ctx, so clean it up regardless.
+	cleanup, cancel := context.WithTimeout(r.ctx, r.HandlerTimeout)
+	defer cancel()
+	_ = s.DeleteAllReactions(cleanup, prompt.ChannelID, prompt.ID)
+
+	if xerrors.Is(err, ErrAwaitTimeout) {
+		_, _ = s.SetMsgContent(cleanup, prompt.ChannelID, prompt.ID, question+"\nTimed out waiting for an answer.")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch evt := evt.(type) {
+	case *disgord.MessageCreate:
+		yes, _ := parseConfirmation(evt.Message.Content)
+		return yes, nil
+	case *disgord.MessageReactionAdd:
+		return evt.PartialEmoji.Name == ConfirmYes, nil
+	}
+
+	return false, nil
+}
+
+// parseConfirmation returns whether an answer to a confirmation prompt means
+// yes. ok is false if it is neither yes nor no.
+func parseConfirmation(answer string) (yes, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "yes", "y":
+		return true, true
+	case "no", "n":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// await waits for the next MESSAGE_CREATE or MESSAGE_REACTION_ADD event that
+// match returns true for.
+func (r *Rikka) await(ctx context.Context, timeout time.Duration, match func(evt interface{}) bool) (interface{}, error) {
+	return r.addAwaiter(match).wait(ctx, timeout)
+}
+
+// addAwaiter starts collecting the first event match returns true for. match
+// is called from event handlers, so it must not block.
+func (r *Rikka) addAwaiter(match func(evt interface{}) bool) *awaiter {
+	a := &awaiter{r: r, match: match, ch: make(chan interface{}, 1)}
+
+	r.awaiters.mu.Lock()
+	r.awaiters.waiting = append(r.awaiters.waiting, a)
+	r.awaiters.mu.Unlock()
+
+	return a
+}
+
+// wait returns the event collected by a.
+func (a *awaiter) wait(ctx context.Context, timeout time.Duration) (interface{}, error) {
+	defer a.r.removeAwaiter(a)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case evt := <-a.ch:
+		return evt, nil
+	case <-timer.C:
+		return nil, ErrAwaitTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-a.r.ctx.Done():
+		return nil, a.r.ctx.Err()
+	}
+}
+
+func (r *Rikka) removeAwaiter(a *awaiter) {
+	r.awaiters.mu.Lock()
+	defer r.awaiters.mu.Unlock()
+
+	for i, e := range r.awaiters.waiting {
+		if e == a {
+			r.awaiters.waiting = append(r.awaiters.waiting[:i], r.awaiters.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliverAwaited hands evt to every pending await it matches. Each await
+// receives at most one event.
+func (r *Rikka) deliverAwaited(evt interface{}) {
+	r.awaiters.mu.Lock()
+	defer r.awaiters.mu.Unlock()
+
+	kept := r.awaiters.waiting[:0]
+	for _, e := range r.awaiters.waiting {
+		if e.match(evt) {
+			e.ch <- evt
+			continue
+		}
+		kept = append(kept, e)
+	}
+	r.awaiters.waiting = kept
+}
+
+func (r *Rikka) handleAwaitedMessage(s disgord.Session, mc *disgord.MessageCreate) {
+	r.deliverAwaited(mc)
+}
+
+func (r *Rikka) handleAwaitedReaction(s disgord.Session, h *disgord.MessageReactionAdd) {
+	r.deliverAwaited(h)
+}
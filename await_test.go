@@ -0,0 +1,123 @@
+package rikka_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/fake"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+// confirmCmd asks for confirmation and reports the answer.
+type confirmCmd struct {
+	*rikka.Rikka
+}
+
+func (c *confirmCmd) Register(fn func(event string, inputs ...interface{})) {}
+func (c *confirmCmd) Help() []rikka.CommandHelp {
+	return []rikka.CommandHelp{{Name: "purge"}}
+}
+func (c *confirmCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	ok, err := c.Confirm(mc.Ctx, s, mc.Message, "Purge 500 messages?")
+	switch {
+	case err != nil:
+		s.SendMsg(mc.Ctx, mc.Message.ChannelID, "error: "+err.Error())
+	case ok:
+		s.SendMsg(mc.Ctx, mc.Message.ChannelID, "purged")
+	default:
+		s.SendMsg(mc.Ctx, mc.Message.ChannelID, "cancelled")
+	}
+}
+
+// startCommand sends content from the harness user in the background and
+// returns the prompt it replies with, and a channel receiving every reply.
+func startCommand(t *testing.T, h *rikkatest.Harness, content string) (*fake.Message, <-chan rikkatest.Replies) {
+	done := make(chan rikkatest.Replies, 1)
+	go func() { done <- h.Send(content) }()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, e := range h.Session.Sent() {
+			if strings.Contains(e.Text(), "Purge") && len(h.Session.Reactions(e.ID)) == 2 {
+				return e, done
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("prompt was not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(&confirmCmd{Rikka: h.Rikka})
+
+	prompt, done := startCommand(t, h, "r.purge")
+	h.SendAs(h.Owner, "yes")
+	h.React(h.Owner, prompt.Message, rikka.ConfirmYes)
+	h.React(h.User, prompt.Message, rikka.ConfirmYes)
+	h.ExpectReply(<-done, "purged")
+	if len(h.Session.Reactions(prompt.ID)) != 0 {
+		t.Fatal("prompt reactions were not removed")
+	}
+
+	// Text answers arrive through the same registration as the command
+	// awaiting them, which the harness locks per event like disgord does.
+	_, done = startCommand(t, h, "r.purge")
+	h.Send("yes")
+	h.ExpectReply(<-done, "purged")
+
+	_, done = startCommand(t, h, "r.purge")
+	h.Send("maybe")
+	h.Send("No")
+	h.ExpectReply(<-done, "cancelled")
+}
+
+func TestAwaitMessage(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := h.Rikka.AwaitMessage(ctx, h.Channel.ID, time.Minute, func(*disgord.Message) bool { return false })
+		errs <- err
+	}()
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("cancelled await returned %v", err)
+	}
+
+	_, err := h.Rikka.AwaitMessage(context.Background(), h.Channel.ID, time.Millisecond, func(*disgord.Message) bool { return true })
+	if err != rikka.ErrAwaitTimeout {
+		t.Fatalf("await returned %v, want a timeout", err)
+	}
+
+	msgs := make(chan *disgord.Message, 1)
+	go func() {
+		msg, _ := h.Rikka.AwaitMessage(context.Background(), h.Channel.ID, time.Minute, func(m *disgord.Message) bool {
+			return m.Content == "second"
+		})
+		msgs <- msg
+	}()
+
+	// Keep sending until the waiter is registered and picks the message up.
+	for {
+		h.Send("first")
+		h.Send("second")
+		select {
+		case msg := <-msgs:
+			if msg.Content != "second" {
+				t.Fatalf("awaited %q", msg.Content)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cdr.dev/slog"
+	"cdr.dev/slog/sloggers/sloghuman"
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/fake"
+)
+
+// runConsole runs commands typed on stdin as if they were sent in a guild, and
+// prints the replies. It does not connect to Discord.
+func runConsole(configPath string, args []string) int {
+	fs := flag.NewFlagSet("console", flag.ExitOnError)
+	var (
+		storageType = fs.String("storage", rikka.StorageMemory, "storage to use, fdb or memory")
+		guildID     = fs.Uint64("guild", 1, "id of the guild messages are sent in, 0 for a direct message")
+		channelID   = fs.Uint64("channel", 2, "id of the channel messages are sent in")
+		userID      = fs.Uint64("user", 3, "id of the user messages are sent by")
+		username    = fs.String("username", "console", "name of the user messages are sent by")
+		owner       = fs.Bool("owner", true, "make the user the bot and guild owner")
+		verbose     = fs.Bool("v", false, "log at the configured level instead of only warnings")
+	)
+	fs.Parse(args)
+
+	cfg, err := rikka.ReadConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cfg.Storage = *storageType
+	if cfg.Token == "" {
+		cfg.Token = "console"
+	}
+
+	r := rikka.New(openStore(cfg), cfg)
+	r.Log = sloghuman.Make(os.Stderr).Leveled(slog.LevelWarn)
+	if *verbose {
+		r.Log = sloghuman.Make(os.Stderr)
+	}
+
+	var (
+		s       = fake.NewSession(&disgord.User{ID: 1 << 40, Username: "rikka", Bot: true})
+		user    = &disgord.User{ID: disgord.Snowflake(*userID), Username: *username}
+		guild   = disgord.Snowflake(*guildID)
+		channel = disgord.Snowflake(*channelID)
+	)
+	s.AddUser(user)
+
+	if guild.IsZero() {
+		s.AddChannel(&disgord.Channel{ID: channel, Type: disgord.ChannelTypeDM, Recipients: []*disgord.User{user}})
+	} else {
+		g := &disgord.Guild{
+			ID:       guild,
+			Name:     "Console",
+			Roles:    []*disgord.Role{{ID: guild, Name: "@everyone", Permissions: disgord.PermissionSendMessages}},
+			Channels: []*disgord.Channel{{ID: channel, Name: "console", Type: disgord.ChannelTypeGuildText}},
+		}
+		if *owner {
+			g.OwnerID = user.ID
+		}
+		s.AddGuild(g)
+		s.AddMember(g.ID, &disgord.Member{User: user})
+	}
+	if *owner {
+		r.Owners = append(r.Owners, user.ID)
+	}
+
+	r.RegisterCommands(botCommands(r, cfg)...)
+	// Jobs in a shared store belong to the running bot, so only run them
+	// when the console has its own store.
+	if cfg.Storage == rikka.StorageMemory {
+		go r.Scheduler.Run(context.Background(), s)
+	}
+
+	fmt.Printf("Type commands as %s, e.g. %shelp. Press Ctrl-D to exit.\n", user.Username, r.Prefixes(guild)[0])
+
+	interactive := false
+	if fi, err := os.Stdin.Stat(); err == nil {
+		interactive = fi.Mode()&os.ModeCharDevice != 0
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("> ")
+		}
+		if !in.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			continue
+		}
+
+		m := &disgord.Message{
+			ID:        s.NewID(),
+			ChannelID: channel,
+			GuildID:   guild,
+			Author:    user,
+			Content:   line,
+			Timestamp: disgord.Time{Time: time.Now()},
+		}
+		s.AddMessage(m)
+
+		before := len(s.Sent())
+		r.DispatchEvent(s, disgord.EvtMessageCreate, &disgord.MessageCreate{Message: m})
+		for _, e := range s.Sent()[before:] {
+			printMessage(os.Stdout, e)
+		}
+	}
+	if interactive {
+		fmt.Println()
+	}
+
+	return 0
+}
+
+// printMessage writes a sent message as plain text, drawing embeds as boxes.
+func printMessage(w io.Writer, m *fake.Message) {
+	if m.Content != "" {
+		fmt.Fprintln(w, m.Content)
+	}
+
+	for _, e := range m.Embeds {
+		var lines []string
+		if e.Author != nil && e.Author.Name != "" {
+			lines = append(lines, e.Author.Name)
+		}
+		if e.Title != "" {
+			lines = append(lines, "# "+e.Title)
+		}
+		if e.Description != "" {
+			lines = append(lines, strings.Split(e.Description, "\n")...)
+		}
+		for _, f := range e.Fields {
+			lines = append(lines, "", f.Name+":")
+			for _, l := range strings.Split(f.Value, "\n") {
+				lines = append(lines, "  "+l)
+			}
+		}
+		if e.Footer != nil && e.Footer.Text != "" {
+			lines = append(lines, "", e.Footer.Text)
+		}
+
+		fmt.Fprintln(w, "+--")
+		for _, l := range lines {
+			fmt.Fprintln(w, "| "+l)
+		}
+		fmt.Fprintln(w, "+--")
+	}
+
+	for _, f := range m.Files {
+		fmt.Fprintf(w, "[file %s, %d bytes]\n", f.Name, len(f.Data))
+	}
+}
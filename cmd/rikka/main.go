@@ -1,25 +1,80 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"cdr.dev/slog"
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 
 	rikka "github.com/coadler/rikka2"
 	"github.com/coadler/rikka2/commands"
 	"github.com/coadler/rikka2/commands/logs"
+	"github.com/coadler/rikka2/storage"
 )
 
 func main() {
-	fdb.MustAPIVersion(620)
-	fdb := fdb.MustOpenDefault()
+	configPath := flag.String("config", os.Getenv("RIKKA_CONFIG"), "path to the YAML config file, RIKKA_* environment variables override its settings")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [console [console flags]]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "console" {
+		os.Exit(runConsole(*configPath, flag.Args()[1:]))
+	}
+
+	cfg, err := rikka.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	r := rikka.New(fdb, Token)
+	r := rikka.New(openStore(cfg), cfg)
+
+	r.RegisterCommands(botCommands(r, cfg)...)
+
+	err = r.Open()
+	if err != nil {
+		r.Log.Fatal(context.Background(), "failed to run bot", slog.Error(err))
+	}
+}
 
-	r.RegisterCommands(
+func botCommands(r *rikka.Rikka, cfg rikka.Config) []rikka.Command {
+	cmds := []rikka.Command{
 		commands.NewPingCommand(r),
 		commands.NewStatsCmd(r),
-		logs.NewLogCmd(r, fdb),
 		commands.NewExecCommand(r),
-		commands.NewSeenCommand(r, fdb),
-	)
-	r.Open()
+		commands.NewSeenCommand(r),
+		commands.NewPrefixCommand(r),
+		commands.NewPermissionsCommand(r),
+		commands.NewModulesCommand(r),
+		commands.NewAuditCommand(r),
+	}
+
+	// The message log needs a blob store, which the console may not have.
+	if cfg.BlobStore.Endpoint != "" {
+		blobs, err := logs.NewMinioBlobStore(cfg.BlobStore)
+		if err != nil {
+			r.Log.Fatal(context.Background(), "failed to open blob store", slog.Error(err))
+		}
+		cmds = append(cmds, logs.NewLogCmd(r, blobs))
+	}
+
+	return cmds
+}
+
+func openStore(cfg rikka.Config) storage.Store {
+	if cfg.Storage == rikka.StorageMemory {
+		return storage.NewMemory()
+	}
+
+	fdb.MustAPIVersion(620)
+	if cfg.FDB.ClusterFile != "" {
+		return storage.NewFDB(fdb.MustOpenDatabase(cfg.FDB.ClusterFile))
+	}
+	return storage.NewFDB(fdb.MustOpenDefault())
 }
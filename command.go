@@ -1,44 +1,33 @@
 package rikka
 
 import (
-	"context"
-	"fmt"
 	"strings"
 
-	"cdr.dev/slog"
 	"github.com/andersfylling/disgord"
 )
 
+// Command is a bot command. Register is used to add passive event listeners,
+// while Handle is invoked by the router whenever a message matches one of the
+// names or aliases returned by Help.
 type Command interface {
 	Register(func(event string, inputs ...interface{}))
 	Help() []CommandHelp
-}
-
-type Args []string
-
-func (a *Args) Pop() string {
-	if len(*a) == 0 {
-		return ""
-	}
-
-	arg := (*a)[0]
-	*a = (*a)[1:]
-	return arg
+	Handle(s disgord.Session, mc *disgord.MessageCreate, args Args)
 }
 
 // MatchesCommandString returns true if a message matches a command.
-// Commands will be matched ignoring case with a prefix if they are not private messages.
-func MatchesCommandString(bot *Rikka, commandString string, private bool, message string) bool {
-	lowerMessage := strings.ToLower(strings.TrimSpace(message))
-	lowerPrefix := strings.ToLower(bot.Prefix)
-
-	if strings.HasPrefix(lowerMessage, lowerPrefix) {
-		lowerMessage = lowerMessage[len(lowerPrefix):]
-	} else if !private {
-		return false
+// Commands will be matched ignoring case with one of the guild's prefixes or a
+// mention of the bot if they are not private messages.
+func MatchesCommandString(bot *Rikka, guildID disgord.Snowflake, commandString string, private bool, message string) bool {
+	lowerMessage, ok := bot.stripPrefix(guildID, message)
+	if !ok {
+		if !private {
+			return false
+		}
+		lowerMessage = message
 	}
 
-	lowerMessage = strings.TrimSpace(lowerMessage)
+	lowerMessage = strings.ToLower(strings.TrimSpace(lowerMessage))
 	lowerCommand := strings.ToLower(commandString)
 
 	return lowerMessage == lowerCommand || strings.HasPrefix(lowerMessage, lowerCommand+" ")
@@ -46,20 +35,15 @@ func MatchesCommandString(bot *Rikka, commandString string, private bool, messag
 
 // MatchesCommand returns true if a message matches a command.
 func MatchesCommand(bot *Rikka, commandString string, message *disgord.Message) bool {
-	return MatchesCommandString(bot, commandString, false, message.Content)
+	return MatchesCommandString(bot, message.GuildID, commandString, false, message.Content)
 }
 
-// ParseCommandString will strip all prefixes from a message string, and return that string, and a space separated tokenized version of that string.
-func ParseCommandString(bot *Rikka, message string) Args {
-	message = strings.TrimSpace(message)
-
-	lowerMessage := strings.ToLower(message)
-	lowerPrefix := strings.ToLower(bot.Prefix)
-
-	if strings.HasPrefix(lowerMessage, lowerPrefix) {
-		message = message[len(lowerPrefix):]
+// ParseCommandString will strip all prefixes from a message string, and return a tokenized version of the arguments following the command name.
+func ParseCommandString(bot *Rikka, guildID disgord.Snowflake, message string) Args {
+	if rest, ok := bot.stripPrefix(guildID, message); ok {
+		message = rest
 	}
-	rest := strings.Fields(message)
+	rest := Tokenize(message)
 
 	if len(rest) > 1 {
 		rest = rest[1:]
@@ -71,13 +55,5 @@ func ParseCommandString(bot *Rikka, message string) Args {
 
 // ParseCommand parses a message.
 func ParseCommand(bot *Rikka, message *disgord.Message) Args {
-	return ParseCommandString(bot, message.Content)
-}
-
-func (r *Rikka) HandleError(ctx context.Context, s disgord.Session, msg *disgord.Message, err error, errMsg string) {
-	r.Log.Error(ctx, errMsg, slog.Error(err))
-	_, err = s.SendMsg(ctx, msg.ChannelID, fmt.Sprintf("%s: %s", errMsg, err))
-	if err != nil {
-		r.Log.Error(ctx, "failed to send error message to channel", slog.Error(err))
-	}
+	return ParseCommandString(bot, message.GuildID, message.Content)
 }
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"github.com/dustin/go-humanize"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+const (
+	// auditMaxEntries is the most invocations the audit command shows.
+	auditMaxEntries = 100
+	auditPageSize   = 10
+)
+
+func NewAuditCommand(r *rikka.Rikka) rikka.Command {
+	return &auditCmd{Rikka: r}
+}
+
+var auditArgs = rikka.ArgSpec{
+	{Name: "user", Type: rikka.ArgUser, Optional: true},
+	{Name: "command", Type: rikka.ArgRest, Optional: true},
+}
+
+type auditCmd struct {
+	*rikka.Rikka
+}
+
+func (c *auditCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *auditCmd) Help() []rikka.CommandHelp {
+	return []rikka.CommandHelp{
+		{
+			Name:        "audit",
+			Aliases:     []string{"auditlog"},
+			Section:     rikka.HelpSectionModeration,
+			Permissions: disgord.PermissionAdministrator,
+			Description: "Page through the commands recently used in this server",
+			Args:        auditArgs,
+			Detailed: "Only administrators and bot owners can view the audit log. " +
+				"Filtering by a command also shows its subcommands, e.g. `log` includes `log messages delete enable`.",
+			Examples: []string{
+				"`%saudit`                    - Show the most recent commands.",
+				"`%saudit @Kitty#0001`        - Show the commands used by a user.",
+				"`%saudit log messages`       - Show uses of `log messages` and its subcommands.",
+				"`%saudit @Kitty#0001 prefix` - Show uses of `prefix` by a user.",
+			},
+		},
+	}
+}
+
+func (c *auditCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	var (
+		ctx     = mc.Ctx
+		guildID = mc.Message.GuildID
+	)
+
+	if guildID.IsZero() {
+		c.HandleError(ctx, s, mc.Message, rikka.BadInput("The audit log can only be viewed in servers"), "Invalid channel")
+		return
+	}
+
+	filter := rikka.AuditFilter{Limit: auditMaxEntries}
+	// The user is optional and comes first, so anything that isn't a user
+	// is the start of the command.
+	if len(args) > 0 {
+		if _, err := rikka.ExtractID(rikka.UserMentionRegex, args[0].Value); err == nil {
+			vals, ok := c.ParseArgs(s, mc, "audit", auditArgs[:1], args[:1])
+			if !ok {
+				return
+			}
+			filter.UserID = vals.User("user").ID
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 {
+		filter.Command = c.canonicalCommand(args.Strings())
+	}
+
+	entries, err := c.AuditLog(guildID, filter)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to load audit log")
+		return
+	}
+	if len(entries) == 0 {
+		c.HandleError(ctx, s, mc.Message, rikka.NotFound("No commands in the audit log match"), "No audit entries")
+		return
+	}
+
+	var (
+		prefix = c.Prefixes(guildID)[0]
+		pages  = (len(entries) + auditPageSize - 1) / auditPageSize
+	)
+	err = c.Paginate(ctx, s, mc.Message, rikka.PageFunc(pages, func(_ context.Context, i int) (*disgord.Embed, error) {
+		end := (i + 1) * auditPageSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		return formatAuditPage(prefix, filter, entries[i*auditPageSize:end]), nil
+	}))
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to send audit log")
+	}
+}
+
+// canonicalCommand resolves an alias at the start of a command path to the
+// command's name, so filtering by either matches the same entries.
+func (c *auditCmd) canonicalCommand(path []string) string {
+	if h, ok := c.LookupCommand(path[0]); ok {
+		path[0] = h.Name
+	}
+
+	return strings.ToLower(strings.Join(path, " "))
+}
+
+func formatAuditPage(prefix string, filter rikka.AuditFilter, entries []rikka.AuditEntry) *disgord.Embed {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		invocation := strings.TrimSpace(prefix + e.Command + " " + e.Args)
+		lines[i] = fmt.Sprintf("`%s`\nby <@%s> in <#%s>, %s in %s, %s",
+			strings.Replace(invocation, "`", "'", -1),
+			e.UserID, e.ChannelID,
+			e.Outcome, e.Latency.Round(time.Millisecond), humanize.Time(e.At),
+		)
+	}
+
+	var filters []string
+	if !filter.UserID.IsZero() {
+		filters = append(filters, fmt.Sprintf("by <@%s>", filter.UserID))
+	}
+	if filter.Command != "" {
+		filters = append(filters, fmt.Sprintf("of `%s%s`", prefix, filter.Command))
+	}
+
+	embed := &disgord.Embed{
+		Title:       "Audit log",
+		Description: strings.Join(lines, "\n\n"),
+	}
+	if len(filters) > 0 {
+		embed.Description = "Commands " + strings.Join(filters, " ") + "\n\n" + embed.Description
+	}
+
+	return embed
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestAudit(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewAuditCommand(h.Rikka), NewModulesCommand(h.Rikka), NewPingCommand(h.Rikka))
+
+	h.Send("r.ping")
+	h.Send("r.modules disable seen")
+	h.SendAs(h.Owner, "r.modules")
+
+	h.ExpectReply(h.Send("r.audit"), "Administrator")
+
+	reply := h.ExpectReply(h.SendAs(h.Owner, "r.audit"), "Audit log")
+	desc := reply.Embeds[0].Description
+	for _, want := range []string{"`r.ping`", "`r.modules disable seen`", "permission", "`r.audit`", "<@3>"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("audit log %q doesn't contain %q", desc, want)
+		}
+	}
+	// Newest first, so the denied audit comes before ping.
+	if strings.Index(desc, "`r.audit`") > strings.Index(desc, "`r.ping`") {
+		t.Errorf("audit log %q isn't newest first", desc)
+	}
+
+	reply = h.ExpectReply(h.SendAs(h.Owner, "r.audit <@3> module"), "Commands by <@3> of `r.modules`")
+	desc = reply.Embeds[0].Description
+	if !strings.Contains(desc, "`r.modules disable seen`") || strings.Contains(desc, "<@2>") || strings.Contains(desc, "`r.ping`") {
+		t.Errorf("filtered audit log %q has the wrong entries", desc)
+	}
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.audit nothing"), "No commands")
+}
@@ -3,7 +3,6 @@ package commands
 import (
 	"fmt"
 	"os/exec"
-	"strings"
 
 	"cdr.dev/slog"
 	"github.com/andersfylling/disgord"
@@ -16,12 +15,18 @@ func NewExecCommand(r *rikka.Rikka) rikka.Command {
 	return &execCmd{Rikka: r}
 }
 
+var execArgs = rikka.ArgSpec{
+	{Name: "command", Type: rikka.ArgRest},
+}
+
 type execCmd struct {
 	*rikka.Rikka
 }
 
-func (c *execCmd) Register(fn func(event string, inputs ...interface{})) {
-	fn("MESSAGE_CREATE", middlewares.BotOwnerOnly, c.handle)
+func (c *execCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *execCmd) Middlewares() []rikka.Middleware {
+	return []rikka.Middleware{middlewares.BotOwnerOnly(c.Rikka)}
 }
 
 func (c *execCmd) Help() []rikka.CommandHelp {
@@ -31,7 +36,7 @@ func (c *execCmd) Help() []rikka.CommandHelp {
 			Aliases:     nil,
 			Section:     rikka.HelpSectionOwner,
 			Description: "Execute shell commands",
-			Usage:       "<command>",
+			Args:        execArgs,
 			Examples: []string{
 				"`%sexec lscpu` - List CPU information.",
 			},
@@ -39,26 +44,20 @@ func (c *execCmd) Help() []rikka.CommandHelp {
 	}
 }
 
-func (c *execCmd) handle(s disgord.Session, mc *disgord.MessageCreate) {
-	if !rikka.MatchesCommand(c.Rikka, "exec", mc.Message) {
-		return
-	}
-
+func (c *execCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
 	ctx := mc.Ctx
 
-	sp := strings.Split(mc.Message.Content, " ")
-	if len(sp) < 2 {
-		s.SendMsg(ctx, mc.Message.ChannelID, "Not enough args")
+	vals, ok := c.ParseArgs(s, mc, "exec", execArgs, args)
+	if !ok {
 		return
 	}
 
-	var cmd *exec.Cmd
-
-	if len(sp[1:]) == 1 {
-		cmd = exec.Command(sp[1])
-	} else {
-		cmd = exec.Command(sp[1], sp[2:]...)
+	sp := rikka.Tokenize(vals.String("command")).Strings()
+	if len(sp) == 0 {
+		c.HandleError(ctx, s, mc.Message, rikka.BadInput("Provide a command to run"), "Invalid input")
+		return
 	}
+	cmd := exec.CommandContext(ctx, sp[0], sp[1:]...)
 
 	const maxOutput = 2000
 	out, _ := cmd.CombinedOutput()
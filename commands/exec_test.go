@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestExecEmpty(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewExecCommand(h.Rikka))
+	h.Rikka.Owners = append(h.Rikka.Owners, h.Owner.ID)
+
+	h.ExpectReply(h.SendAs(h.Owner, `r.exec ""`), "Provide a command to run")
+	h.ExpectReply(h.SendAs(h.Owner, `r.exec "   "`), "Provide a command to run")
+}
@@ -0,0 +1,78 @@
+package logs
+
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go"
+	"golang.org/x/xerrors"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+// BlobStore stores the attachments of logged messages.
+type BlobStore interface {
+	// Put stores size bytes from r under key and returns the amount written.
+	// size is -1 if unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error)
+	// Get returns the blob stored under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+}
+
+// NewMinioBlobStore connects to the bucket described by cfg, creating it if
+// it doesn't exist.
+func NewMinioBlobStore(cfg rikka.BlobStoreConfig) (BlobStore, error) {
+	mc, err := minio.New(cfg.Endpoint, cfg.AccessKey, cfg.SecretKey, cfg.Secure)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to connect to minio: %w", err)
+	}
+
+	exists, err := mc.BucketExists(cfg.Bucket)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to check if bucket exists: %w", err)
+	}
+
+	if !exists {
+		err := mc.MakeBucket(cfg.Bucket, "")
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create message attachments bucket: %w", err)
+		}
+	}
+
+	return &minioBlobStore{client: mc, bucket: cfg.Bucket}, nil
+}
+
+type minioBlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func (m *minioBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	return m.client.PutObjectWithContext(ctx, m.bucket, key, r, size, minio.PutObjectOptions{})
+}
+
+func (m *minioBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return m.client.GetObjectWithContext(ctx, m.bucket, key, minio.GetObjectOptions{})
+}
+
+// Ping checks that the bucket exists. minio-go does not take a context for
+// this, so the request is abandoned when ctx is done.
+func (m *minioBlobStore) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		exists, err := m.client.BucketExists(m.bucket)
+		if err == nil && !exists {
+			err = xerrors.Errorf("bucket %q does not exist", m.bucket)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
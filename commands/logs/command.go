@@ -1,92 +1,42 @@
 package logs
 
 import (
-	"sync"
-
 	"github.com/andersfylling/disgord"
-	"github.com/apple/foundationdb/bindings/go/src/fdb"
 
 	rikka "github.com/coadler/rikka2"
-	"github.com/coadler/rikka2/middlewares"
 )
 
-func NewLogCmd(r *rikka.Rikka, fdb fdb.Database) rikka.Command {
-	return &logCmd{
-		Rikka: r,
-		fdb:   fdb,
-		commands: map[string]logSection{
-			"messages": newMessageLog(r, fdb),
-		},
-	}
-}
+func NewLogCmd(r *rikka.Rikka, blobs BlobStore) rikka.Command {
+	messages := newMessageLog(r, blobs)
 
-func (c *logCmd) Help() []rikka.CommandHelp {
-	return []rikka.CommandHelp{
-		{
+	return &logCmd{
+		Group: rikka.NewGroup(r, rikka.CommandHelp{
 			Name:        "log",
 			Aliases:     nil,
 			Section:     rikka.HelpSectionModeration,
-			Description: "Execute shell commands",
-			Usage:       "<command>",
+			Permissions: disgord.PermissionManageServer,
+			Description: "Configure moderation logs",
 			Examples: []string{
-				"`%sexec lscpu` - List CPU information.",
+				"`%slog messages delete enable #logs` - Log deleted messages to #logs.",
+				"`%slog messages update enable`       - Log edited messages to the current channel.",
+				"`%slog messages update disable`      - Stop logging edited messages.",
 			},
-		},
+		}, messages.subcommand()),
+		messages: messages,
 	}
 }
 
-type logSection interface {
-	rikka.Command
-
-	handleCommand(s disgord.Session, h *disgord.MessageCreate, args rikka.Args)
-}
-
+// logCmd routes log commands to the subcommands of each log section. Since
+// log sections have no knowledge of each other, each one provides its own
+// subcommands and listeners.
 type logCmd struct {
-	*rikka.Rikka
+	*rikka.Group
 
-	fdb fdb.Database
-
-	cmdMu    sync.Mutex
-	commands map[string]logSection
+	messages *messageLog
 }
 
 func (c *logCmd) Register(fn func(event string, inputs ...interface{})) {
-	for _, e := range c.commands {
-		e.Register(fn)
-	}
-
-	// Since log sections have no knowlege of each other, they must have
-	// commands routed to them from the base logCmd.
-	// TODO: user permissions and not just server owner
-	fn("MESSAGE_CREATE", middlewares.ServerOwnerOnly(c.Rikka), c.handleCommand)
+	c.messages.Register(fn)
 }
 
-// handleCommand routes log commands to their respective section or displays
-// the help message if none are found.
-func (c *logCmd) handleCommand(s disgord.Session, mc *disgord.MessageCreate) {
-	if !rikka.MatchesCommand(c.Rikka, "log", mc.Message) {
-		return
-	}
-
-	ctx := mc.Ctx
-
-	args := rikka.ParseCommand(c.Rikka, mc.Message)
-	if len(args) < 1 {
-		// eventually send good help info here
-		s.SendMsg(ctx, mc.Message.ChannelID, "Please provide a section")
-		return
-	}
-
-	// if handleCommand is called concurrently this map is safe
-	c.cmdMu.Lock()
-	sect, ok := c.commands[args.Pop()]
-	c.cmdMu.Unlock()
-	if !ok {
-		// incorrect section
-		s.SendMsg(ctx, mc.Message.ChannelID, "Section not found")
-		return
-	}
-
-	// pass through event to the correct section
-	sect.handleCommand(s, mc, args)
-}
+func (c *logCmd) Module() string { return "logs" }
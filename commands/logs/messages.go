@@ -10,76 +10,62 @@ import (
 
 	"cdr.dev/slog"
 	"github.com/andersfylling/disgord"
-	"github.com/apple/foundationdb/bindings/go/src/fdb"
-	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"github.com/bwmarrin/discordgo"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/minio/minio-go"
 	"golang.org/x/xerrors"
 
 	rikka "github.com/coadler/rikka2"
 	"github.com/coadler/rikka2/middlewares"
+	"github.com/coadler/rikka2/storage"
 )
 
-func newMessageLog(r *rikka.Rikka, fdb fdb.Database) logSection {
-	dir, err := directory.CreateOrOpen(fdb, []string{"rikka", "logs", "message_track"}, nil)
+func newMessageLog(r *rikka.Rikka, blobs BlobStore) *messageLog {
+	dir, err := r.Directory("rikka", "logs", "message_track")
 	if err != nil {
 		r.Log.Fatal(context.Background(), "failed to create directory", slog.Error(err))
 	}
 
-	mc, err := minio.New("127.0.0.1:9000", "H7E789JVJLYIDN9B0KB2", "N5RNoaakuJ1UG7TFgPCgmcnrNKgza38Eg0d8BYsB", false)
-	if err != nil {
-		r.Log.Fatal(context.Background(), "failed to connect to minio", slog.Error(err))
-	}
-
-	const bucket = "message-attachments"
-	bucketExists, err := mc.BucketExists(bucket)
-	if err != nil {
-		r.Log.Fatal(context.Background(), "check if bucket exists", slog.Error(err))
-	}
-
-	if !bucketExists {
-		err := mc.MakeBucket(bucket, "")
-		if err != nil {
-			r.Log.Fatal(context.Background(), "failed to create message attachments bucket", slog.Error(err))
-		}
-	}
+	r.AddHealthCheck("blob_store", blobs.Ping)
 
 	return &messageLog{
-		Rikka:            r,
-		fdb:              fdb,
-		dir:              dir,
-		minio:            mc,
-		attachmentBucket: bucket,
+		Rikka: r,
+		dir:   dir,
+		blobs: blobs,
 	}
 }
 
+var messageLogChannelArgs = rikka.ArgSpec{
+	{Name: "channel", Type: rikka.ArgChannel, Optional: true},
+}
+
 type messageLog struct {
 	*rikka.Rikka
 
-	fdb fdb.Database
-	dir directory.DirectorySubspace
+	dir storage.Subspace
 
-	minio            *minio.Client
-	attachmentBucket string
+	blobs BlobStore
 }
 
-func (c *messageLog) Help() []rikka.CommandHelp {
-	const detailed = ``
-
-	return []rikka.CommandHelp{
-		{
-			Name:        "messages",
-			Aliases:     nil,
-			Section:     rikka.HelpSectionModeration,
-			Description: "Log updates or deletes",
-			Usage:       "<update | delete> <enable | disable> [channel id]",
-			Detailed:    detailed,
-			Examples: []string{
-				"`%slog messages enable`                    - Enable message logging to the current channel.",
-				"`%slog messages enable 644376487331495967` - Enable message logging to the provided channel id.",
-				"`%slog messages enable #my-log-channel`    - Enable message logging to the provided channel mention.",
+// subcommand returns the `log messages` subcommands.
+func (c *messageLog) subcommand() rikka.Subcommand {
+	return rikka.Subcommand{
+		Name:        "messages",
+		Description: "Log updates or deletes",
+		Subcommands: []rikka.Subcommand{
+			{
+				Name: "delete",
+				Subcommands: []rikka.Subcommand{
+					{Name: "enable", Description: "Log deleted messages to a channel, or the current one", Args: messageLogChannelArgs, Handle: c.handleDeleteEnable},
+					{Name: "disable", Description: "Stop logging deleted messages", Handle: c.handleDeleteDisable},
+				},
+			},
+			{
+				Name: "update",
+				Subcommands: []rikka.Subcommand{
+					{Name: "enable", Description: "Log edited messages to a channel, or the current one", Args: messageLogChannelArgs, Handle: c.handleUpdateEnable},
+					{Name: "disable", Description: "Stop logging edited messages", Handle: c.handleUpdateDisable},
+				},
 			},
 		},
 	}
@@ -91,126 +77,79 @@ func (c *messageLog) Register(fn func(event string, inputs ...interface{})) {
 	fn("MESSAGE_DELETE", middlewares.NoBots, c.logDelete)
 }
 
-func (c *messageLog) handleCommand(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
-	if len(args) < 1 {
-		// send help
-		return
+// logChannel returns the channel argument, defaulting to the channel the
+// command was sent in.
+func (c *messageLog) logChannel(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) (*disgord.Channel, error) {
+	if ch := vals.Channel("channel"); ch != nil {
+		return ch, nil
 	}
 
-	typ := args.Pop()
-	switch typ {
-	case "delete":
-		c.handleDeleteCommand(s, mc, args)
-	case "update":
-		c.handleUpdateCommand(s, mc, args)
-	default:
-		fmt.Println(typ)
-	}
+	return s.GetChannel(mc.Ctx, mc.Message.ChannelID)
 }
 
-// <enable | disable> [channel]
-
-func (c *messageLog) handleDeleteCommand(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
-	var (
-		ctx       = mc.Ctx
-		action    = args.Pop()
-		channelID = args.Pop()
-	)
-
-	switch action {
-	case "enable":
-		cid, err := rikka.ExtractID(rikka.ChannelMentionRegex, channelID)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to extract channel id")
-			return
-		}
-
-		// make sure the channel exists
-		ch, err := s.GetChannel(ctx, cid)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to retrieve log channel")
-			return
-		}
-
-		err = c.enableDeleteLog(ch.GuildID, ch.ID)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
-			return
-		}
-
-		s.SendMsg(ctx, mc.Message.ChannelID, "Enabling delete logs in "+ch.Mention())
-		return
-
-	case "disable":
-		// err := c.disableDeleteLog(ch.GuildID, ch.ID)
-		// if err != nil {
-		// 	c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
-		// 	return
-		// }
+func (c *messageLog) handleDeleteEnable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
 
-		s.SendMsg(ctx, mc.Message.ChannelID, "Disabling delete logs")
+	ch, err := c.logChannel(s, mc, vals)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to retrieve log channel")
 		return
+	}
 
-	default:
-		s.SendMsg(ctx, mc.Message.ChannelID, "Unknown action. Available actions are: [enable, disable]")
+	err = c.enableDeleteLog(ch.GuildID, ch.ID)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
 		return
 	}
-}
 
-// <enable | disable> [channel]
-
-func (c *messageLog) handleUpdateCommand(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
-	var (
-		ctx       = mc.Ctx
-		action    = args.Pop()
-		channelID = args.Pop()
-	)
+	s.SendMsg(ctx, mc.Message.ChannelID, "Enabling delete logs in "+ch.Mention())
+}
 
-	switch action {
-	case "enable":
-		cid, err := rikka.ExtractID(rikka.ChannelMentionRegex, channelID)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to extract channel id")
-			return
-		}
+func (c *messageLog) handleDeleteDisable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	// err := c.disableDeleteLog(ch.GuildID, ch.ID)
+	// if err != nil {
+	// 	c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
+	// 	return
+	// }
 
-		// make sure the channel exists
-		ch, err := s.GetChannel(ctx, cid)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to retrieve log channel")
-			return
-		}
+	s.SendMsg(mc.Ctx, mc.Message.ChannelID, "Disabling delete logs")
+}
 
-		err = c.enableUpdateLog(ch.GuildID, ch.ID)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "Failed to enable update logging")
-			return
-		}
+func (c *messageLog) handleUpdateEnable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
 
-		s.SendMsg(ctx, mc.Message.ChannelID, "Enabled update logs in "+ch.Mention())
+	ch, err := c.logChannel(s, mc, vals)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to retrieve log channel")
 		return
+	}
 
-	case "disable":
-		enabled, ch := c.updateLogIsEnabled(mc.Message.GuildID)
-		if !enabled {
-			s.SendMsg(ctx, mc.Message.ChannelID, "Update logs are not enabled")
-			return
-		}
-		_ = ch
+	err = c.enableUpdateLog(ch.GuildID, ch.ID)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to enable update logging")
+		return
+	}
 
-		// err := c.disableUpdateLog(mc.Message.GuildID, ch)
-		// if err != nil {
-		// 	c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
-		// 	return
-		// }
+	s.SendMsg(ctx, mc.Message.ChannelID, "Enabled update logs in "+ch.Mention())
+}
 
-		s.SendMsg(ctx, mc.Message.ChannelID, "Disabled update logging")
-		return
+func (c *messageLog) handleUpdateDisable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
 
-	default:
-		s.SendMsg(ctx, mc.Message.ChannelID, "Unknown action. Available actions are: [enable, disable]")
+	enabled, ch := c.updateLogIsEnabled(mc.Message.GuildID)
+	if !enabled {
+		s.SendMsg(ctx, mc.Message.ChannelID, "Update logs are not enabled")
 		return
 	}
+	_ = ch
+
+	// err := c.disableUpdateLog(mc.Message.GuildID, ch)
+	// if err != nil {
+	// 	c.HandleError(ctx, s, mc.Message, err, "Failed to enable delete logging")
+	// 	return
+	// }
+
+	s.SendMsg(ctx, mc.Message.ChannelID, "Disabled update logging")
 }
 
 func (c *messageLog) storeMessage(s disgord.Session, mc *disgord.MessageCreate) {
@@ -221,24 +160,28 @@ func (c *messageLog) storeMessage(s disgord.Session, mc *disgord.MessageCreate)
 	ctx := mc.Ctx
 
 	for _, e := range mc.Message.Attachments {
-		resp, err := http.Get(e.ProxyURL)
+		req, err := http.NewRequest(http.MethodGet, e.ProxyURL, nil)
+		if err != nil {
+			c.Log.Error(ctx, "failed to create message attachment request", slog.Error(err))
+			attachmentFailures.WithLabelValues("download").Inc()
+			continue
+		}
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 		if err != nil {
 			c.Log.Error(ctx, "failed to get message attachment", slog.Error(err))
+			attachmentFailures.WithLabelValues("download").Inc()
 			continue
 		}
 		defer resp.Body.Close()
 
-		_, err = c.minio.PutObject(
-			c.attachmentBucket,
-			fmt.Sprintf("%d/%d", mc.Message.ID, e.ID),
-			resp.Body,
-			resp.ContentLength,
-			minio.PutObjectOptions{},
-		)
+		n, err := c.blobs.Put(ctx, fmtAttachmentKey(mc.Message.ID, e.ID), resp.Body, resp.ContentLength)
 		if err != nil {
 			c.Log.Error(ctx, "failed to upload message attachment", slog.Error(err))
+			attachmentFailures.WithLabelValues("upload").Inc()
 			continue
 		}
+		attachmentBytes.Observe(float64(n))
 	}
 
 	raw, err := jsoniter.Marshal(mc.Message)
@@ -247,7 +190,7 @@ func (c *messageLog) storeMessage(s disgord.Session, mc *disgord.MessageCreate)
 		return
 	}
 
-	err = c.Transact(func(t fdb.Transaction) error {
+	err = c.Transact(func(t storage.Transaction) error {
 		t.Set(c.fmtMessageKey(mc.Message.ID), raw)
 		return nil
 	})
@@ -273,7 +216,7 @@ func (c *messageLog) logDelete(s disgord.Session, md *disgord.MessageDelete) {
 
 	attachments := []disgord.CreateMessageFileParams{}
 	for _, e := range oldMsg.Attachments {
-		obj, err := c.minio.GetObject(c.attachmentBucket, fmt.Sprintf("%d/%d", md.MessageID, e.ID), minio.GetObjectOptions{})
+		obj, err := c.blobs.Get(ctx, fmtAttachmentKey(md.MessageID, e.ID))
 		if err != nil {
 			c.Log.Error(ctx, "failed to retrieve attachment from cache", slog.Error(err))
 			continue
@@ -446,11 +389,20 @@ func (c *messageLog) logUpdate(s disgord.Session, mu *disgord.MessageUpdate) {
 func (c *messageLog) guildIsEnabled(guildID disgord.Snowflake) bool {
 	var enabled bool
 
-	err := c.ReadTransact(func(t fdb.ReadTransaction) error {
+	err := c.ReadTransact(func(t storage.ReadTransaction) error {
 		ss := t.Snapshot()
-		enabled = false ||
-			ss.Get(c.fmtDeleteEnabledKey(guildID)).MustGet() != nil ||
-			ss.Get(c.fmtUpdateEnabledKey(guildID)).MustGet() != nil
+
+		del, err := ss.Get(c.fmtDeleteEnabledKey(guildID))
+		if err != nil {
+			return err
+		}
+
+		upd, err := ss.Get(c.fmtUpdateEnabledKey(guildID))
+		if err != nil {
+			return err
+		}
+
+		enabled = del != nil || upd != nil
 		return nil
 	})
 	if err != nil {
@@ -462,7 +414,7 @@ func (c *messageLog) guildIsEnabled(guildID disgord.Snowflake) bool {
 }
 
 func (c *messageLog) enableUpdateLog(guildID, channel disgord.Snowflake) error {
-	err := c.Transact(func(t fdb.Transaction) error {
+	err := c.Transact(func(t storage.Transaction) error {
 		idRaw := [8]byte{}
 		binary.BigEndian.PutUint64(idRaw[:], uint64(channel))
 
@@ -477,7 +429,7 @@ func (c *messageLog) enableUpdateLog(guildID, channel disgord.Snowflake) error {
 }
 
 func (c *messageLog) enableDeleteLog(guildID, channel disgord.Snowflake) error {
-	err := c.Transact(func(t fdb.Transaction) error {
+	err := c.Transact(func(t storage.Transaction) error {
 		idRaw := [8]byte{}
 		binary.BigEndian.PutUint64(idRaw[:], uint64(channel))
 
@@ -492,8 +444,11 @@ func (c *messageLog) enableDeleteLog(guildID, channel disgord.Snowflake) error {
 }
 
 func (c *messageLog) updateLogIsEnabled(guildID disgord.Snowflake) (enabled bool, channel disgord.Snowflake) {
-	err := c.ReadTransact(func(t fdb.ReadTransaction) error {
-		raw := t.Snapshot().Get(c.fmtUpdateEnabledKey(guildID)).MustGet()
+	err := c.ReadTransact(func(t storage.ReadTransaction) error {
+		raw, err := t.Snapshot().Get(c.fmtUpdateEnabledKey(guildID))
+		if err != nil {
+			return err
+		}
 		enabled = raw != nil
 
 		if enabled {
@@ -510,8 +465,11 @@ func (c *messageLog) updateLogIsEnabled(guildID disgord.Snowflake) (enabled bool
 }
 
 func (c *messageLog) deleteLogIsEnabled(guildID disgord.Snowflake) (enabled bool, channel disgord.Snowflake) {
-	err := c.ReadTransact(func(t fdb.ReadTransaction) error {
-		raw := t.Snapshot().Get(c.fmtDeleteEnabledKey(guildID)).MustGet()
+	err := c.ReadTransact(func(t storage.ReadTransaction) error {
+		raw, err := t.Snapshot().Get(c.fmtDeleteEnabledKey(guildID))
+		if err != nil {
+			return err
+		}
 		enabled = raw != nil
 
 		if enabled {
@@ -535,9 +493,10 @@ func (c *messageLog) messageFromCache(id disgord.Snowflake) (*disgord.Message, e
 		msg    disgord.Message
 	)
 
-	err := c.ReadTransact(func(t fdb.ReadTransaction) error {
-		rawMsg = t.Snapshot().Get(c.fmtMessageKey(id)).MustGet()
-		return nil
+	err := c.ReadTransact(func(t storage.ReadTransaction) error {
+		var err error
+		rawMsg, err = t.Snapshot().Get(c.fmtMessageKey(id))
+		return err
 	})
 	if err != nil {
 		return nil, xerrors.Errorf("failed to transact message from cache: %w", err)
@@ -555,14 +514,18 @@ func (c *messageLog) messageFromCache(id disgord.Snowflake) (*disgord.Message, e
 	return &msg, nil
 }
 
-func (c *messageLog) fmtDeleteEnabledKey(guildID disgord.Snowflake) fdb.Key {
+func (c *messageLog) fmtDeleteEnabledKey(guildID disgord.Snowflake) storage.Key {
 	return c.dir.Sub(0).Pack(tuple.Tuple{uint64(guildID)})
 }
 
-func (c *messageLog) fmtUpdateEnabledKey(guildID disgord.Snowflake) fdb.Key {
+func (c *messageLog) fmtUpdateEnabledKey(guildID disgord.Snowflake) storage.Key {
 	return c.dir.Sub(1).Pack(tuple.Tuple{uint64(guildID)})
 }
 
-func (c *messageLog) fmtMessageKey(msgID disgord.Snowflake) fdb.Key {
+func fmtAttachmentKey(msgID, attachmentID disgord.Snowflake) string {
+	return fmt.Sprintf("%d/%d", msgID, attachmentID)
+}
+
+func (c *messageLog) fmtMessageKey(msgID disgord.Snowflake) storage.Key {
 	return c.dir.Sub(2).Pack(tuple.Tuple{uint64(msgID)})
 }
@@ -0,0 +1,102 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+// memoryBlobs is a BlobStore kept in memory.
+type memoryBlobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newMemoryBlobs() *memoryBlobs {
+	return &memoryBlobs{blobs: map[string][]byte{}}
+}
+
+func (m *memoryBlobs) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.blobs[key] = data
+	m.mu.Unlock()
+
+	return int64(len(data)), nil
+}
+
+func (m *memoryBlobs) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, xerrors.Errorf("blob %q not found", key)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryBlobs) Ping(ctx context.Context) error { return nil }
+
+func TestMessageLog(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewLogCmd(h.Rikka, newMemoryBlobs()))
+
+	logs := &disgord.Channel{ID: h.Session.NewID(), GuildID: h.Guild.ID, Name: "logs", Type: disgord.ChannelTypeGuildText}
+	h.Session.AddChannel(logs)
+
+	h.ExpectReply(h.Send("r.log messages delete enable"), "Manage Server")
+	h.ExpectReply(h.SendAs(h.Owner, "r.log messages delete enable "+logs.Mention()), "Enabling delete logs in "+logs.Mention())
+	h.ExpectReply(h.SendAs(h.Owner, "r.log messages update enable "+logs.Mention()), "Enabled update logs in "+logs.Mention())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "meow")
+	}))
+	defer srv.Close()
+
+	// Messages are cached as JSON, which disgord can't decode single digit ids
+	// from, so use a realistic author id.
+	author := &disgord.User{ID: h.Session.NewID(), Username: "author", Discriminator: 3}
+	h.Session.AddMember(h.Guild.ID, &disgord.Member{User: author})
+
+	msg := h.NewMessage("hello")
+	msg.Author = author
+	msg.Attachments = []*disgord.Attachment{{ID: h.Session.NewID(), Filename: "cat.txt", ProxyURL: srv.URL}}
+	h.ExpectNoReply(h.MessageCreate(msg))
+
+	edited := h.NewMessage("goodbye")
+	edited.ID = msg.ID
+	edited.Author = author
+	reply := h.ExpectReply(h.MessageUpdate(edited), "Message edited")
+	if reply.ChannelID != logs.ID {
+		t.Fatalf("expected update log in %s, got %s", logs.ID, reply.ChannelID)
+	}
+	if !strings.Contains(reply.Text(), "hello") {
+		t.Fatalf("expected update log to contain the old content, got %q", reply.Text())
+	}
+
+	reply = h.ExpectReply(h.MessageDelete(msg), "Message Deleted")
+	if reply.ChannelID != logs.ID {
+		t.Fatalf("expected delete log in %s, got %s", logs.ID, reply.ChannelID)
+	}
+	if len(reply.Files) != 1 || reply.Files[0].Name != "cat.txt" || string(reply.Files[0].Data) != "meow" {
+		t.Fatalf("expected the attachment to be reuploaded, got %+v", reply.Files)
+	}
+}
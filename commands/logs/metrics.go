@@ -0,0 +1,20 @@
+package logs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	attachmentBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "rikka",
+		Name:      "attachment_upload_bytes",
+		Help:      "Size of message attachments saved to the blob store.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+	attachmentFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "attachment_failures_total",
+		Help:      "Message attachments that could not be saved, by stage.",
+	}, []string{"stage"})
+)
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+func NewModulesCommand(r *rikka.Rikka) rikka.Command {
+	c := &modulesCmd{Rikka: r}
+	c.Group = rikka.NewGroup(r, rikka.CommandHelp{
+		Name:        "modules",
+		Aliases:     []string{"module"},
+		Section:     rikka.HelpSecionGeneral,
+		Description: "View, enable or disable command modules in this server",
+		Usage:       "[enable | disable] ...",
+		Detailed: "Disabling a module turns off its commands and stops it from listening to events in this server, " +
+			"e.g. disabling `seen` stops recording when members last typed. The `core` module is always enabled.",
+		Examples: []string{
+			"`%smodules`              - List modules and whether they are enabled.",
+			"`%smodules disable seen` - Disable the seen module.",
+			"`%smodules enable seen`  - Enable the seen module again.",
+		},
+	},
+		rikka.Subcommand{
+			Name:        "enable",
+			Description: "Enable a module",
+			Args:        rikka.ArgSpec{{Name: "module", Type: rikka.ArgString}},
+			Permissions: disgord.PermissionManageServer,
+			Handle:      c.handleEnable,
+		},
+		rikka.Subcommand{
+			Name:        "disable",
+			Description: "Disable a module",
+			Args:        rikka.ArgSpec{{Name: "module", Type: rikka.ArgString}},
+			Permissions: disgord.PermissionManageServer,
+			Handle:      c.handleDisable,
+		},
+	)
+
+	return c
+}
+
+type modulesCmd struct {
+	*rikka.Group
+	*rikka.Rikka
+}
+
+// Handle lists the modules and whether they are enabled when no subcommand is
+// given, and routes to the subcommands otherwise.
+func (c *modulesCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	var (
+		ctx     = mc.Ctx
+		guildID = mc.Message.GuildID
+	)
+
+	if guildID.IsZero() {
+		c.HandleError(ctx, s, mc.Message, rikka.BadInput("Modules can only be changed in servers"), "Invalid channel")
+		return
+	}
+
+	if len(args) == 0 {
+		disabled, err := c.DisabledModules(guildID)
+		if err != nil {
+			c.HandleError(ctx, s, mc.Message, err, "Failed to load modules")
+			return
+		}
+
+		s.SendMsg(ctx, mc.Message.ChannelID, c.formatModules(disabled))
+		return
+	}
+
+	c.Group.Handle(s, mc, args)
+}
+
+func (c *modulesCmd) handleEnable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
+	module := vals.String("module")
+
+	err := c.EnableModule(mc.Message.GuildID, module)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to enable module")
+		return
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, fmt.Sprintf("Enabled the `%s` module.", strings.ToLower(module)))
+}
+
+func (c *modulesCmd) handleDisable(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
+	module := vals.String("module")
+
+	err := c.DisableModule(mc.Message.GuildID, module)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to disable module")
+		return
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, fmt.Sprintf("Disabled the `%s` module.", strings.ToLower(module)))
+}
+
+func (c *modulesCmd) formatModules(disabled map[string]bool) string {
+	var b strings.Builder
+	b.WriteString("**Modules**\n")
+
+	for _, e := range c.Modules() {
+		status := "enabled"
+		if disabled[e] {
+			status = "disabled"
+		}
+
+		fmt.Fprintf(&b, "`%s` (%s): %s\n", e, status, strings.Join(c.ModuleCommands(e), ", "))
+	}
+
+	return b.String()
+}
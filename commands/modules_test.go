@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestModules(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewModulesCommand(h.Rikka), NewSeenCommand(h.Rikka))
+
+	h.ExpectReply(h.Send("r.modules"), "`seen` (enabled)")
+	h.ExpectReply(h.Send("r.modules disable seen"), "Manage Server permission to use `modules disable`")
+	h.ExpectReply(h.SendAs(h.Owner, "r.modules disable"), "Usage: `r.modules disable <module>`")
+	h.ExpectReply(h.SendAs(h.Owner, "r.modules disable core"), "can't be disabled")
+	h.ExpectReply(h.SendAs(h.Owner, "r.modules disable nope"), "No module named")
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.modules disable seen"), "Disabled the `seen` module")
+	h.ExpectNoReply(h.Send("r.seen"))
+
+	// The seen listener is disabled too, so this message isn't recorded.
+	quiet := &disgord.User{ID: h.Session.NewID(), Username: "quiet"}
+	h.Session.AddMember(h.Guild.ID, &disgord.Member{User: quiet})
+	h.SendAs(quiet, "hello")
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.modules enable SEEN"), "Enabled the `seen` module")
+	reply := h.ExpectReply(h.SendAs(h.Owner, "r.seen "+quiet.ID.String()), "Last seen")
+	if reply.Embeds[0].Fields[0].Value != "Never" {
+		t.Fatalf("expected message sent while disabled to be ignored, got %q", reply.Embeds[0].Fields[0].Value)
+	}
+}
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+func NewPermissionsCommand(r *rikka.Rikka) rikka.Command {
+	return &permissionsCmd{Rikka: r}
+}
+
+var permissionsArgs = rikka.ArgSpec{
+	{Name: "command", Type: rikka.ArgString},
+	{Name: "action", Type: rikka.ArgEnum, Choices: []string{"allow", "deny", "reset"}, Optional: true},
+	{Name: "target", Type: rikka.ArgString, Optional: true},
+}
+
+type permissionsCmd struct {
+	*rikka.Rikka
+}
+
+func (c *permissionsCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *permissionsCmd) Help() []rikka.CommandHelp {
+	return []rikka.CommandHelp{
+		{
+			Name:        "permissions",
+			Aliases:     []string{"perms"},
+			Section:     rikka.HelpSectionModeration,
+			Permissions: disgord.PermissionManageServer,
+			Description: "Grant or deny commands to users, roles and channels",
+			Args:        permissionsArgs,
+			Detailed: "Overrides are checked from most to least specific: user, channel, then role. " +
+				"Without an override the Discord permissions a command requires are used. " +
+				"Administrators can always use every command.",
+			Examples: []string{
+				"`%sperms seen`                    - View the overrides for seen.",
+				"`%sperms log allow @Moderators`   - Allow the Moderators role to use log.",
+				"`%sperms seen deny #general`      - Disable seen in #general.",
+				"`%sperms seen deny everyone`      - Disable seen for everyone.",
+				"`%sperms seen reset @Kitty#0001`  - Remove the override for a user.",
+			},
+		},
+	}
+}
+
+func (c *permissionsCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	ctx := mc.Ctx
+
+	vals, ok := c.ParseArgs(s, mc, "permissions", permissionsArgs, args)
+	if !ok {
+		return
+	}
+
+	help, ok := c.LookupCommand(vals.String("command"))
+	if !ok {
+		c.HandleError(ctx, s, mc.Message, rikka.NotFound("Unknown command `%s`", vals.String("command")), "Unknown command")
+		return
+	}
+
+	action := vals.String("action")
+	if action == "" {
+		c.listOverrides(s, mc, help)
+		return
+	}
+
+	input := vals.String("target")
+	if input == "" {
+		c.HandleError(ctx, s, mc.Message, rikka.BadInput("Please provide a user, role or channel"), "Missing target")
+		return
+	}
+
+	target, id, err := resolvePermissionTarget(ctx, s, mc.Message.GuildID, input)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to resolve target")
+		return
+	}
+
+	switch action {
+	case "allow", "deny":
+		err = c.SetPermissionOverride(mc.Message.GuildID, rikka.PermissionOverride{
+			Command: help.Name,
+			Target:  target,
+			ID:      id,
+			Allow:   action == "allow",
+		})
+		if err != nil {
+			c.HandleError(ctx, s, mc.Message, err, "Failed to set permission override")
+			return
+		}
+
+		verb := "Allowed"
+		if action == "deny" {
+			verb = "Denied"
+		}
+		s.SendMsg(ctx, mc.Message.ChannelID, fmt.Sprintf("%s `%s` for %s", verb, help.Name, target.Mention(id)))
+
+	case "reset":
+		err = c.ClearPermissionOverride(mc.Message.GuildID, help.Name, target, id)
+		if err != nil {
+			c.HandleError(ctx, s, mc.Message, err, "Failed to clear permission override")
+			return
+		}
+
+		s.SendMsg(ctx, mc.Message.ChannelID, fmt.Sprintf("Removed the `%s` override for %s", help.Name, target.Mention(id)))
+	}
+}
+
+func (c *permissionsCmd) listOverrides(s disgord.Session, mc *disgord.MessageCreate, help rikka.CommandHelp) {
+	ctx := mc.Ctx
+
+	overrides, err := c.PermissionOverrides(mc.Message.GuildID)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to load permission overrides")
+		return
+	}
+
+	required := "None"
+	if perms := rikka.PermissionNames(help.Permissions); len(perms) > 0 {
+		required = strings.Join(perms, ", ")
+	}
+
+	var lines []string
+	for _, e := range overrides {
+		if e.Command != help.Name {
+			continue
+		}
+
+		state := "Allowed"
+		if !e.Allow {
+			state = "Denied"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", e.Target, e.Target.Mention(e.ID), state))
+	}
+
+	list := "No overrides"
+	if len(lines) > 0 {
+		list = strings.Join(lines, "\n")
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, disgord.CreateMessageParams{
+		Embed: &disgord.Embed{
+			Title: "Permissions for " + help.Name,
+			Fields: []*disgord.EmbedField{
+				{Name: "Required permissions", Value: required},
+				{Name: "Overrides", Value: list},
+			},
+		},
+	})
+}
+
+// resolvePermissionTarget resolves a mention, id or role name to the target it
+// refers to. "everyone" refers to the @everyone role.
+func resolvePermissionTarget(ctx context.Context, s disgord.Session, guildID disgord.Snowflake, input string) (rikka.PermissionTarget, disgord.Snowflake, error) {
+	switch {
+	case strings.EqualFold(strings.TrimPrefix(input, "@"), "everyone"):
+		return rikka.PermissionTargetRole, guildID, nil
+	case rikka.UserMentionRegex.MatchString(input):
+		id, err := rikka.ExtractID(rikka.UserMentionRegex, input)
+		return rikka.PermissionTargetUser, id, err
+	case rikka.RoleMentionRegex.MatchString(input):
+		id, err := rikka.ExtractID(rikka.RoleMentionRegex, input)
+		return rikka.PermissionTargetRole, id, err
+	case rikka.ChannelMentionRegex.MatchString(input):
+		id, err := rikka.ExtractID(rikka.ChannelMentionRegex, input)
+		return rikka.PermissionTargetChannel, id, err
+	}
+
+	// Ids and role names are ambiguous, so check everything in the guild
+	// they may refer to.
+	roles, err := s.GetGuildRoles(ctx, guildID)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("failed to load guild roles: %w", err)
+	}
+
+	id, _ := rikka.ExtractID(rikka.UserMentionRegex, input)
+	for _, e := range roles {
+		if e.ID == id || strings.EqualFold(e.Name, input) {
+			return rikka.PermissionTargetRole, e.ID, nil
+		}
+	}
+
+	if id.IsZero() {
+		return 0, 0, rikka.NotFound("No user, role or channel found for `%s`", input)
+	}
+
+	if ch, err := s.GetChannel(ctx, id); err == nil && ch.GuildID == guildID {
+		return rikka.PermissionTargetChannel, id, nil
+	}
+
+	if _, err := s.GetMember(ctx, guildID, id); err == nil {
+		return rikka.PermissionTargetUser, id, nil
+	}
+
+	return 0, 0, rikka.NotFound("No user, role or channel found for `%s`", input)
+}
@@ -17,9 +17,9 @@ type pingCmd struct {
 	bot *rikka.Rikka
 }
 
-func (c *pingCmd) Register(fn func(event string, inputs ...interface{})) {
-	fn("MESSAGE_CREATE", c.handle)
-}
+func (c *pingCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *pingCmd) Module() string { return "info" }
 
 func (c *pingCmd) Help() []rikka.CommandHelp {
 	return []rikka.CommandHelp{
@@ -36,11 +36,7 @@ func (c *pingCmd) Help() []rikka.CommandHelp {
 	}
 }
 
-func (c *pingCmd) handle(s disgord.Session, mc *disgord.MessageCreate) {
-	if !rikka.MatchesCommand(c.bot, "ping", mc.Message) {
-		return
-	}
-
+func (c *pingCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, _ rikka.Args) {
 	ctx := mc.Ctx
 	start := time.Now()
 
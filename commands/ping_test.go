@@ -0,0 +1,20 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestPing(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewPingCommand(h.Rikka))
+
+	reply := h.ExpectReply(h.Send("r.ping"), "Pong!")
+	if !strings.HasPrefix(reply.Content, "Pong! - `") {
+		t.Fatalf("pong was not edited with the latency: %q", reply.Content)
+	}
+
+	h.ExpectNoReply(h.Send("ping"))
+}
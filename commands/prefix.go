@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+func NewPrefixCommand(r *rikka.Rikka) rikka.Command {
+	c := &prefixCmd{Rikka: r}
+	c.Group = rikka.NewGroup(r, rikka.CommandHelp{
+		Name:        "prefix",
+		Aliases:     []string{"prefixes"},
+		Section:     rikka.HelpSecionGeneral,
+		Description: "View or change the command prefixes for this server",
+		Usage:       "[set | reset | suggestions] ...",
+		Detailed: "Mentioning the bot always works as a prefix, even if the server's prefixes are changed.\n\n" +
+			"Unknown commands are answered with similar command names. If a prefix is shared with another bot, " +
+			"turn this off with `suggestions off`.",
+		Examples: []string{
+			"`%sprefix`                 - View the current prefixes.",
+			"`%sprefix set ! r.`        - Use `!` and `r.` as prefixes.",
+			"`%sprefix reset`           - Restore the default prefix.",
+			"`%sprefix suggestions off` - Stop suggesting commands for unknown ones.",
+		},
+	},
+		rikka.Subcommand{
+			Name:        "set",
+			Description: "Replace the prefixes",
+			Args:        rikka.ArgSpec{{Name: "prefixes", Type: rikka.ArgRest}},
+			Permissions: disgord.PermissionManageServer,
+			Handle:      c.handleSet,
+		},
+		rikka.Subcommand{
+			Name:        "reset",
+			Description: "Restore the default prefix",
+			Permissions: disgord.PermissionManageServer,
+			Handle:      c.handleReset,
+		},
+		rikka.Subcommand{
+			Name:        "suggestions",
+			Description: "Turn suggestions for unknown commands on or off",
+			Args:        rikka.ArgSpec{{Name: "state", Type: rikka.ArgEnum, Choices: []string{"on", "off"}}},
+			Permissions: disgord.PermissionManageServer,
+			Handle:      c.handleSuggestions,
+		},
+	)
+
+	return c
+}
+
+type prefixCmd struct {
+	*rikka.Group
+	*rikka.Rikka
+}
+
+// Handle shows the current prefixes when no subcommand is given, and routes
+// to the subcommands otherwise. Private messages show the default prefix, and
+// the subcommands can only be used in servers.
+func (c *prefixCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	if len(args) == 0 {
+		s.SendMsg(mc.Ctx, mc.Message.ChannelID, "Current prefixes: "+formatPrefixes(c.Prefixes(mc.Message.GuildID)))
+		return
+	}
+
+	c.Group.Handle(s, mc, args)
+}
+
+func (c *prefixCmd) handleSet(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	var (
+		ctx     = mc.Ctx
+		guildID = mc.Message.GuildID
+	)
+
+	err := c.SetPrefixes(guildID, strings.Fields(vals.String("prefixes")))
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to set prefixes")
+		return
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, "Prefixes set to "+formatPrefixes(c.Prefixes(guildID)))
+}
+
+func (c *prefixCmd) handleReset(s disgord.Session, mc *disgord.MessageCreate, _ rikka.ArgValues) {
+	var (
+		ctx     = mc.Ctx
+		guildID = mc.Message.GuildID
+	)
+
+	err := c.ResetPrefixes(guildID)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to reset prefixes")
+		return
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, "Prefixes reset to "+formatPrefixes(c.Prefixes(guildID)))
+}
+
+func (c *prefixCmd) handleSuggestions(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+	ctx := mc.Ctx
+	enabled := vals.String("state") == "on"
+
+	err := c.SetSuggestions(mc.Message.GuildID, enabled)
+	if err != nil {
+		c.HandleError(ctx, s, mc.Message, err, "Failed to change suggestions")
+		return
+	}
+
+	if enabled {
+		s.SendMsg(ctx, mc.Message.ChannelID, "Unknown commands will be answered with suggestions")
+	} else {
+		s.SendMsg(ctx, mc.Message.ChannelID, "Unknown commands will be ignored")
+	}
+}
+
+func formatPrefixes(prefixes []string) string {
+	quoted := make([]string, len(prefixes))
+	for i, e := range prefixes {
+		quoted[i] = "`" + e + "`"
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestPrefix(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewPrefixCommand(h.Rikka), NewPingCommand(h.Rikka))
+
+	h.ExpectReply(h.Send("r.prefix"), "Current prefixes: `r.`")
+	h.ExpectReply(h.Send("r.prefix set !"), "Manage Server")
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix set ! ?"), "Prefixes set to `!`, `?`")
+	h.ExpectReply(h.Send("?ping"), "Pong!")
+	h.ExpectNoReply(h.Send("r.ping"))
+
+	h.ExpectReply(h.SendAs(h.Owner, "!prefix reset"), "Prefixes reset to `r.`")
+	h.ExpectReply(h.Send("r.ping"), "Pong!")
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix set"), "Usage: `r.prefix set <prefixes...>`")
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix add !"), "Unknown subcommand `add` for `prefix`")
+
+	// Private messages show the default prefix but can't change it.
+	dm := h.NewMessage("r.prefix")
+	dm.GuildID = 0
+	h.ExpectReply(h.MessageCreate(dm), "Current prefixes: `r.`")
+	dm = h.NewMessage("r.prefix set !")
+	dm.GuildID = 0
+	h.ExpectReply(h.MessageCreate(dm), "can only be used in servers")
+
+	help := NewPrefixCommand(h.Rikka).Help()[0]
+	for _, want := range []string{"`set <prefixes...>`", "`reset`", "`suggestions <on | off>`"} {
+		if !strings.Contains(help.Detailed, want) {
+			t.Errorf("help %q doesn't contain %q", help.Detailed, want)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewPrefixCommand(h.Rikka), NewPingCommand(h.Rikka))
+
+	h.ExpectReply(h.Send("r.pign"), "Did you mean `r.ping`?")
+	h.ExpectNoReply(h.Send("r.definitelynotacommand"))
+
+	h.ExpectReply(h.Send("r.prefix suggestions off"), "Manage Server")
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix suggestions maybe"), "must be one of [on, off]")
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix suggestions off"), "ignored")
+	h.ExpectNoReply(h.Send("r.pign"))
+	h.ExpectReply(h.SendAs(h.Owner, "r.prefix suggestions on"), "suggestions")
+	h.ExpectReply(h.Send("r.prefx"), "Did you mean `r.prefix`?")
+}
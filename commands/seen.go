@@ -3,22 +3,20 @@ package commands
 import (
 	"context"
 	"encoding/binary"
-	"strconv"
 	"time"
 
 	"cdr.dev/slog"
 	"github.com/andersfylling/disgord"
-	"github.com/apple/foundationdb/bindings/go/src/fdb"
-	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"github.com/dustin/go-humanize"
 	"golang.org/x/xerrors"
 
 	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/storage"
 )
 
-func NewSeenCommand(r *rikka.Rikka, fdb fdb.Database) rikka.Command {
-	dir, err := directory.CreateOrOpen(fdb, []string{"rikka", "seen"}, nil)
+func NewSeenCommand(r *rikka.Rikka) rikka.Command {
+	dir, err := r.Directory("rikka", "seen")
 	if err != nil {
 		r.Log.Fatal(context.Background(), "failed to create directory", slog.Error(err))
 	}
@@ -29,16 +27,22 @@ func NewSeenCommand(r *rikka.Rikka, fdb fdb.Database) rikka.Command {
 	}
 }
 
+var seenArgs = rikka.ArgSpec{
+	{Name: "user", Type: rikka.ArgUser, Optional: true},
+}
+
 type seenCmd struct {
 	*rikka.Rikka
 
-	dir directory.DirectorySubspace
+	dir storage.Subspace
 }
 
 func (c *seenCmd) Register(fn func(event string, inputs ...interface{})) {
-	fn("MESSAGE_CREATE", c.handleSeen, c.handleCommand)
+	fn("MESSAGE_CREATE", c.handleSeen)
 }
 
+func (c *seenCmd) Module() string { return "seen" }
+
 func (c *seenCmd) Help() []rikka.CommandHelp {
 	return []rikka.CommandHelp{
 		{
@@ -46,49 +50,34 @@ func (c *seenCmd) Help() []rikka.CommandHelp {
 			Aliases:     []string{"lastseen", "lastactive"},
 			Section:     rikka.HelpSecionInfo,
 			Description: "See the last time a user typed in the current channel and guild",
-			Usage:       "<mention | user id>",
+			Cooldowns: []rikka.Cooldown{
+				{Bucket: rikka.CooldownUser, Burst: 3, Per: 15 * time.Second},
+				{Bucket: rikka.CooldownGuild, Burst: 20, Per: time.Minute},
+			},
+			Args: seenArgs,
 			Examples: []string{
-				"`%sseen @Kitty#0001`           - Use a mention to see seen stats.",
-				"`%sseen @Kitty#0001 @thy#0001` - Query multiple users at a time.",
-				"`%sseen 105484726235607040`    - Use an id to see seen stats.",
+				"`%sseen`                    - See your own seen stats.",
+				"`%sseen @Kitty#0001`        - Use a mention to see seen stats.",
+				"`%sseen 105484726235607040` - Use an id to see seen stats.",
 			},
 		},
 	}
 }
 
-func (c *seenCmd) handleCommand(s disgord.Session, mc *disgord.MessageCreate) {
-	if !rikka.MatchesCommand(c.Rikka, "seen", mc.Message) {
-		return
-	}
+func (c *seenCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	ctx := mc.Ctx
 
-	var (
-		ctx    = mc.Ctx
-		userID disgord.Snowflake
-	)
-
-	parts := rikka.ParseCommand(c.Rikka, mc.Message)
-	if len(parts) == 0 {
-		userID = mc.Message.Author.ID
-	} else if len(parts) == 1 {
-		parsed, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			c.HandleError(ctx, s, mc.Message, err, "failed to parse user id")
-			return
-		}
-
-		userID = disgord.Snowflake(parsed)
-	} else {
-		s.SendMsg(ctx, mc.Message.ChannelID, "Please only supply one user")
+	vals, ok := c.ParseArgs(s, mc, "seen", seenArgs, args)
+	if !ok {
 		return
 	}
 
-	user, err := s.GetUser(ctx, userID)
-	if err != nil {
-		c.HandleError(ctx, s, mc.Message, err, "failed to find user")
-		return
+	user := vals.User("user")
+	if user == nil {
+		user = mc.Message.Author
 	}
 
-	lastChannel, lastGuild, err := c.load(userID, mc.Message.ChannelID, mc.Message.GuildID)
+	lastChannel, lastGuild, err := c.load(user.ID, mc.Message.ChannelID, mc.Message.GuildID)
 	if err != nil {
 		c.HandleError(ctx, s, mc.Message, err, "failed to load last seen times")
 		return
@@ -128,10 +117,15 @@ func (c *seenCmd) load(userID, channelID, guildID disgord.Snowflake) (channel, g
 		gRaw []byte
 	)
 
-	err = c.ReadTransact(func(t fdb.ReadTransaction) error {
-		cRaw = t.Get(c.fmtLastSeenKey(userID, channelID)).MustGet()
-		gRaw = t.Get(c.fmtLastSeenKey(userID, guildID)).MustGet()
-		return nil
+	err = c.ReadTransact(func(t storage.ReadTransaction) error {
+		var err error
+		cRaw, err = t.Get(c.fmtLastSeenKey(userID, channelID))
+		if err != nil {
+			return err
+		}
+
+		gRaw, err = t.Get(c.fmtLastSeenKey(userID, guildID))
+		return err
 	})
 	if err != nil {
 		return time.Time{}, time.Time{}, xerrors.Errorf("failed to transact last seen times: %w", err)
@@ -151,19 +145,24 @@ func (c *seenCmd) load(userID, channelID, guildID disgord.Snowflake) (channel, g
 }
 
 func (c *seenCmd) handleSeen(s disgord.Session, mc *disgord.MessageCreate) {
+	// messages with a nil author are embeds sent by bots.
+	if mc.Message.Author == nil {
+		return
+	}
+
 	var (
 		now    = time.Now()
 		nowRaw [8]byte
 	)
 	binary.BigEndian.PutUint64(nowRaw[:], uint64(now.UnixNano()))
 
-	c.Transact(func(t fdb.Transaction) error {
+	c.Transact(func(t storage.Transaction) error {
 		t.Set(c.fmtLastSeenKey(mc.Message.Author.ID, mc.Message.ChannelID), nowRaw[:])
 		t.Set(c.fmtLastSeenKey(mc.Message.Author.ID, mc.Message.GuildID), nowRaw[:])
 		return nil
 	})
 }
 
-func (c *seenCmd) fmtLastSeenKey(user, location disgord.Snowflake) fdb.Key {
+func (c *seenCmd) fmtLastSeenKey(user, location disgord.Snowflake) storage.Key {
 	return c.dir.Pack(tuple.Tuple{uint64(location), uint64(user)})
 }
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestSeen(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewSeenCommand(h.Rikka))
+
+	reply := h.ExpectReply(h.SendAs(h.Owner, "r.seen "+h.User.ID.String()), "Last seen")
+	if reply.Embeds[0].Fields[0].Value != "Never" {
+		t.Fatalf("expected user to have never been seen, got %q", reply.Embeds[0].Fields[0].Value)
+	}
+
+	h.Send("hello")
+
+	reply = h.ExpectReply(h.SendAs(h.Owner, "r.seen "+h.User.ID.String()), "Last seen")
+	if reply.Embeds[0].Fields[0].Value == "Never" {
+		t.Fatal("expected user to have been seen")
+	}
+
+	h.ExpectReply(h.SendAs(h.Owner, "r.seen nobody"), "Invalid input")
+}
@@ -22,9 +22,9 @@ type statsCmd struct {
 	start time.Time
 }
 
-func (c *statsCmd) Register(fn func(event string, inputs ...interface{})) {
-	fn("MESSAGE_CREATE", c.handle)
-}
+func (c *statsCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *statsCmd) Module() string { return "info" }
 
 func (c *statsCmd) Help() []rikka.CommandHelp {
 	return []rikka.CommandHelp{
@@ -33,7 +33,11 @@ func (c *statsCmd) Help() []rikka.CommandHelp {
 			Aliases:     nil,
 			Section:     rikka.HelpSecionInfo,
 			Description: "See bot stats",
-			Usage:       "",
+			Cooldowns: []rikka.Cooldown{
+				{Bucket: rikka.CooldownUser, Burst: 1, Per: 10 * time.Second},
+				{Bucket: rikka.CooldownGlobal, Burst: 5, Per: time.Minute},
+			},
+			Usage: "",
 			Examples: []string{
 				"`%sstats` - See bot stats",
 			},
@@ -41,19 +45,15 @@ func (c *statsCmd) Help() []rikka.CommandHelp {
 	}
 }
 
-func (c *statsCmd) handle(s disgord.Session, mc *disgord.MessageCreate) {
-	if !rikka.MatchesCommand(c.Rikka, "stats", mc.Message) {
-		return
-	}
-
+func (c *statsCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, _ rikka.Args) {
 	ctx := mc.Ctx
 
 	memstats := runtime.MemStats{}
 	runtime.ReadMemStats(&memstats)
 
-	self, err := c.Client.Myself(ctx)
+	self, err := s.GetCurrentUser(ctx)
 	if err != nil {
-		s.SendMsg(ctx, mc.Message.ChannelID, "Failed to generate stats: "+err.Error())
+		c.HandleError(ctx, s, mc.Message, err, "Failed to generate stats")
 		return
 	}
 
@@ -67,7 +67,7 @@ func (c *statsCmd) handle(s disgord.Session, mc *disgord.MessageCreate) {
 	for _, e := range guilds {
 		g, err := s.GetGuild(ctx, e)
 		if err != nil {
-			s.SendMsg(ctx, mc.Message.ChannelID, "Failed to generate stats: "+err.Error())
+			c.HandleError(ctx, s, mc.Message, err, "Failed to generate stats")
 			return
 		}
 
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestStats(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(NewStatsCmd(h.Rikka))
+
+	reply := h.ExpectReply(h.Send("r.stats"), "Rikka v2")
+
+	fields := map[string]string{}
+	for _, e := range reply.Embeds[0].Fields {
+		fields[e.Name] = e.Value
+	}
+	for name, want := range map[string]string{"Guilds": "1", "Channels": "1", "Users": "2"} {
+		if fields[name] != want {
+			t.Errorf("expected %s to be %s, got %q", name, want, fields[name])
+		}
+	}
+
+	// The user cooldown allows one use every ten seconds.
+	h.ExpectReply(h.Send("r.stats"), "Slow down!")
+}
@@ -0,0 +1,216 @@
+package rikka
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config configures the bot. It is loaded from a YAML file and can be
+// overridden by RIKKA_* environment variables.
+type Config struct {
+	// Token is the Discord bot token. Env: RIKKA_TOKEN.
+	Token string `yaml:"token"`
+	// Owners are the ids of the bot owners, who can use owner only commands.
+	// Env: RIKKA_OWNERS, comma separated.
+	Owners []disgord.Snowflake `yaml:"owners"`
+	// Prefix is the default command prefix. Env: RIKKA_PREFIX.
+	Prefix string `yaml:"prefix"`
+	// LogLevel is one of debug, info, warn or error. Env: RIKKA_LOG_LEVEL.
+	LogLevel string `yaml:"log_level"`
+	// ErrorChannel is the id of a channel full error reports are sent to.
+	// Reports are only logged if empty. Env: RIKKA_ERROR_CHANNEL.
+	ErrorChannel disgord.Snowflake `yaml:"error_channel"`
+	// HTTPAddr is the address to serve /metrics, /healthz and /readyz on,
+	// e.g. ":9090". Disabled if empty. Env: RIKKA_HTTP_ADDR.
+	HTTPAddr string `yaml:"http_addr"`
+
+	// Storage is where state is kept, either fdb or memory. The memory store
+	// is lost on exit and only meant for local development. Env:
+	// RIKKA_STORAGE.
+	Storage   string          `yaml:"storage"`
+	FDB       FDBConfig       `yaml:"fdb"`
+	BlobStore BlobStoreConfig `yaml:"blob_store"`
+}
+
+type FDBConfig struct {
+	// ClusterFile is the path to the FDB cluster file. The default cluster
+	// file is used if empty. Env: RIKKA_FDB_CLUSTER_FILE.
+	ClusterFile string `yaml:"cluster_file"`
+}
+
+// BlobStoreConfig configures the S3 compatible store message attachments are
+// saved to.
+type BlobStoreConfig struct {
+	// Env: RIKKA_BLOB_ENDPOINT.
+	Endpoint string `yaml:"endpoint"`
+	// Env: RIKKA_BLOB_ACCESS_KEY.
+	AccessKey string `yaml:"access_key"`
+	// Env: RIKKA_BLOB_SECRET_KEY.
+	SecretKey string `yaml:"secret_key"`
+	// Env: RIKKA_BLOB_BUCKET.
+	Bucket string `yaml:"bucket"`
+	// Secure enables TLS. Env: RIKKA_BLOB_SECURE.
+	Secure bool `yaml:"secure"`
+}
+
+const (
+	StorageFDB    = "fdb"
+	StorageMemory = "memory"
+)
+
+// DefaultConfig returns a Config with every optional setting set.
+func DefaultConfig() Config {
+	return Config{
+		Prefix:   "r.",
+		LogLevel: "info",
+		Storage:  StorageFDB,
+		BlobStore: BlobStoreConfig{
+			Bucket: "message-attachments",
+		},
+	}
+}
+
+// LoadConfig reads the config file at path, applies environment overrides and
+// validates the result. If path is empty only the environment is used.
+func LoadConfig(path string) (Config, error) {
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// ReadConfig is LoadConfig without validation, for modes that do not need
+// every setting.
+func ReadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Config{}, xerrors.Errorf("failed to read config file: %w", err)
+		}
+
+		err = yaml.UnmarshalStrict(raw, &cfg)
+		if err != nil {
+			return Config{}, xerrors.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	err := cfg.applyEnv(os.LookupEnv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"RIKKA_TOKEN":            &c.Token,
+		"RIKKA_PREFIX":           &c.Prefix,
+		"RIKKA_LOG_LEVEL":        &c.LogLevel,
+		"RIKKA_HTTP_ADDR":        &c.HTTPAddr,
+		"RIKKA_STORAGE":          &c.Storage,
+		"RIKKA_FDB_CLUSTER_FILE": &c.FDB.ClusterFile,
+		"RIKKA_BLOB_ENDPOINT":    &c.BlobStore.Endpoint,
+		"RIKKA_BLOB_ACCESS_KEY":  &c.BlobStore.AccessKey,
+		"RIKKA_BLOB_SECRET_KEY":  &c.BlobStore.SecretKey,
+		"RIKKA_BLOB_BUCKET":      &c.BlobStore.Bucket,
+	}
+	for env, field := range strs {
+		if v, ok := lookup(env); ok {
+			*field = v
+		}
+	}
+
+	if v, ok := lookup("RIKKA_OWNERS"); ok {
+		c.Owners = nil
+		for _, e := range strings.Split(v, ",") {
+			e = strings.TrimSpace(e)
+			if e == "" {
+				continue
+			}
+
+			id, err := strconv.ParseUint(e, 10, 64)
+			if err != nil {
+				return xerrors.Errorf("invalid owner id %q in RIKKA_OWNERS: %w", e, err)
+			}
+			c.Owners = append(c.Owners, disgord.Snowflake(id))
+		}
+	}
+
+	if v, ok := lookup("RIKKA_ERROR_CHANNEL"); ok {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return xerrors.Errorf("invalid RIKKA_ERROR_CHANNEL %q: %w", v, err)
+		}
+		c.ErrorChannel = disgord.Snowflake(id)
+	}
+
+	if v, ok := lookup("RIKKA_BLOB_SECURE"); ok {
+		secure, err := strconv.ParseBool(v)
+		if err != nil {
+			return xerrors.Errorf("invalid RIKKA_BLOB_SECURE %q: %w", v, err)
+		}
+		c.BlobStore.Secure = secure
+	}
+
+	return nil
+}
+
+// Validate returns an error describing every invalid setting.
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Token == "" {
+		problems = append(problems, "token is required")
+	}
+	if c.Prefix == "" || len(c.Prefix) > MaxPrefixLength || strings.ContainsAny(c.Prefix, " \t\n") {
+		problems = append(problems, "prefix must be 1-"+strconv.Itoa(MaxPrefixLength)+" characters without whitespace")
+	}
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		problems = append(problems, "log_level must be one of debug, info, warn or error")
+	}
+	if c.Storage != StorageFDB && c.Storage != StorageMemory {
+		problems = append(problems, "storage must be fdb or memory")
+	}
+	if c.BlobStore.Endpoint == "" {
+		problems = append(problems, "blob_store.endpoint is required")
+	}
+	if c.BlobStore.AccessKey == "" {
+		problems = append(problems, "blob_store.access_key is required")
+	}
+	if c.BlobStore.SecretKey == "" {
+		problems = append(problems, "blob_store.secret_key is required")
+	}
+	if c.BlobStore.Bucket == "" {
+		problems = append(problems, "blob_store.bucket is required")
+	}
+
+	if len(problems) > 0 {
+		return xerrors.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
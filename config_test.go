@@ -0,0 +1,88 @@
+package rikka
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rikka.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+token: file-token
+owners: [105484726235607040]
+blob_store:
+  endpoint: 127.0.0.1:9000
+  access_key: access
+  secret_key: secret
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("RIKKA_TOKEN", "env-token")
+	os.Setenv("RIKKA_BLOB_SECURE", "true")
+	defer os.Unsetenv("RIKKA_TOKEN")
+	defer os.Unsetenv("RIKKA_BLOB_SECURE")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Token != "env-token" {
+		t.Errorf("Token = %q, want env override", cfg.Token)
+	}
+	if len(cfg.Owners) != 1 || cfg.Owners[0] != disgord.Snowflake(105484726235607040) {
+		t.Errorf("Owners = %v", cfg.Owners)
+	}
+	if cfg.Prefix != "r." || cfg.BlobStore.Bucket != "message-attachments" {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+	if !cfg.BlobStore.Secure {
+		t.Error("BlobStore.Secure not overridden")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogLevel = "loud"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, want := range []string{"token", "log_level", "blob_store.endpoint", "blob_store.secret_key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestConfigApplyEnvOwners(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.applyEnv(func(key string) (string, bool) {
+		if key == "RIKKA_OWNERS" {
+			return "1, 2,", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.Owners) != 2 || cfg.Owners[0] != 1 || cfg.Owners[1] != 2 {
+		t.Errorf("Owners = %v", cfg.Owners)
+	}
+}
@@ -0,0 +1,188 @@
+package rikka
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord"
+)
+
+// CooldownBucket decides who shares a cooldown.
+type CooldownBucket int
+
+const (
+	CooldownUser CooldownBucket = iota
+	CooldownChannel
+	CooldownGuild
+	CooldownGlobal
+)
+
+func (b CooldownBucket) String() string {
+	switch b {
+	case CooldownUser:
+		return "user"
+	case CooldownChannel:
+		return "channel"
+	case CooldownGuild:
+		return "server"
+	case CooldownGlobal:
+		return "everyone"
+	default:
+		panic("unknown cooldown bucket: " + strconv.FormatInt(int64(b), 10))
+	}
+}
+
+// Cooldown limits a command to Burst uses every Per within a bucket. Uses are
+// refilled gradually, so after the burst is spent one use becomes available
+// every Per / Burst. Burst and Per must be positive, or registering the
+// command fails.
+type Cooldown struct {
+	Bucket CooldownBucket
+	Burst  int
+	Per    time.Duration
+}
+
+func (c Cooldown) String() string {
+	return fmt.Sprintf("%d per %s per %s", c.Burst, c.Per, c.Bucket)
+}
+
+// key returns the id of the bucket e falls into.
+func (c Cooldown) key(name string, e *Event) string {
+	var id disgord.Snowflake
+	switch c.Bucket {
+	case CooldownUser:
+		id = e.UserID
+	case CooldownChannel:
+		id = e.ChannelID
+	case CooldownGuild:
+		id = e.GuildID
+		if id.IsZero() {
+			id = e.ChannelID
+		}
+	}
+
+	return name + ":" + strconv.Itoa(int(c.Bucket)) + ":" + id.String()
+}
+
+// limiter is an in memory token bucket rate limiter.
+type limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled completely and can be
+	// forgotten.
+	full time.Time
+}
+
+type limit struct {
+	key      string
+	cooldown Cooldown
+}
+
+// take consumes a use from every limit if all of them have one available.
+// Otherwise nothing is consumed and retry is how long until all of them do.
+func (l *limiter) take(now time.Time, limits []limit) (ok bool, retry time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[string]*tokenBucket{}
+	}
+	l.sweep(now)
+
+	buckets := make([]*tokenBucket, len(limits))
+	for i, e := range limits {
+		b, ok := l.buckets[e.key]
+		if !ok {
+			b = &tokenBucket{tokens: float64(e.cooldown.Burst), last: now}
+			l.buckets[e.key] = b
+		}
+
+		rate := float64(e.cooldown.Burst) / float64(e.cooldown.Per)
+		b.tokens = math.Min(float64(e.cooldown.Burst), b.tokens+float64(now.Sub(b.last))*rate)
+		b.last = now
+		buckets[i] = b
+
+		if b.tokens < 1 {
+			if wait := time.Duration((1 - b.tokens) / rate); wait > retry {
+				retry = wait
+			}
+		}
+	}
+
+	if retry > 0 {
+		return false, retry
+	}
+
+	for i, e := range limits {
+		rate := float64(e.cooldown.Burst) / float64(e.cooldown.Per)
+		buckets[i].tokens--
+		buckets[i].full = now.Add(time.Duration((float64(e.cooldown.Burst) - buckets[i].tokens) / rate))
+	}
+
+	return true, 0
+}
+
+// sweep forgets buckets that have refilled completely, at most once a minute.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		if !b.full.After(now) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// cooldownNotice limits how often a user is told they are on cooldown, so the
+// notice itself cannot be used to spam a channel.
+var cooldownNotice = Cooldown{Bucket: CooldownUser, Burst: 1, Per: 10 * time.Second}
+
+// checkCooldowns consumes a use of each of the command's cooldowns. If any is
+// exhausted, the author is told when to try again unless they were told
+// recently. Bot owners are exempt.
+func (r *Rikka) checkCooldowns(s disgord.Session, mc *disgord.MessageCreate, help CommandHelp) bool {
+	if len(help.Cooldowns) == 0 || (mc.Message.Author != nil && r.IsOwner(mc.Message.Author.ID)) {
+		return true
+	}
+
+	e := NewEvent(s, mc)
+	ok, retry := r.TakeCooldown(help.Name, e, help.Cooldowns...)
+	if ok {
+		return true
+	}
+
+	if ok, _ := r.TakeCooldown("", e, cooldownNotice); ok {
+		secs := int(math.Ceil(retry.Seconds()))
+		r.HandleError(mc.Ctx, s, mc.Message, &UserError{
+			Kind:    ErrorKindBadInput,
+			Message: fmt.Sprintf("Slow down! Try `%s` again in %ds.", help.Name, secs),
+		}, "Command on cooldown")
+	}
+
+	return false
+}
+
+// TakeCooldown consumes a use of each cooldown from the buckets e falls in,
+// keyed by name so unrelated cooldowns do not share buckets. If any of them
+// is exhausted nothing is consumed and retry is how long until all of them
+// have a use available.
+func (r *Rikka) TakeCooldown(name string, e *Event, cooldowns ...Cooldown) (ok bool, retry time.Duration) {
+	limits := make([]limit, len(cooldowns))
+	for i, c := range cooldowns {
+		limits[i] = limit{key: c.key(name, e), cooldown: c}
+	}
+
+	return r.cooldowns.take(time.Now(), limits)
+}
@@ -0,0 +1,61 @@
+package rikka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	var (
+		l     limiter
+		now   = time.Now()
+		user  = limit{key: "user", cooldown: Cooldown{Bucket: CooldownUser, Burst: 2, Per: 10 * time.Second}}
+		guild = limit{key: "guild", cooldown: Cooldown{Bucket: CooldownGuild, Burst: 3, Per: time.Minute}}
+	)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take(now, []limit{user, guild}); !ok {
+			t.Fatalf("take %d: expected ok", i)
+		}
+	}
+
+	ok, retry := l.take(now, []limit{user, guild})
+	if ok {
+		t.Fatal("expected user bucket to be exhausted")
+	}
+	if retry != 5*time.Second {
+		t.Fatalf("retry = %s, want 5s", retry)
+	}
+
+	// A denied take must not consume from the guild bucket.
+	if ok, _ := l.take(now, []limit{guild}); !ok {
+		t.Fatal("expected guild bucket to have one use left")
+	}
+
+	now = now.Add(5 * time.Second)
+	ok, retry = l.take(now, []limit{user, guild})
+	if ok {
+		t.Fatal("expected guild bucket to be exhausted")
+	}
+	if retry != 15*time.Second {
+		t.Fatalf("retry = %s, want 15s", retry)
+	}
+
+	if ok, _ := l.take(now, []limit{user}); !ok {
+		t.Fatal("expected user bucket to have refilled")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	var (
+		l   limiter
+		now = time.Now()
+		c   = limit{key: "a", cooldown: Cooldown{Burst: 1, Per: time.Second}}
+	)
+
+	l.take(now, []limit{c})
+	l.take(now.Add(2*time.Minute), []limit{{key: "b", cooldown: c.cooldown}})
+	if _, ok := l.buckets["a"]; ok {
+		t.Fatal("expected refilled bucket to be swept")
+	}
+}
@@ -0,0 +1,51 @@
+package rikka_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+// blockingCmd blocks until release is closed.
+type blockingCmd struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCmd) Register(fn func(event string, inputs ...interface{})) {}
+func (c *blockingCmd) Help() []rikka.CommandHelp {
+	return []rikka.CommandHelp{{Name: "slow"}}
+}
+func (c *blockingCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	close(c.started)
+	<-c.release
+	s.SendMsg(mc.Ctx, mc.Message.ChannelID, "done")
+}
+
+func TestSlowCommandDoesNotBlockOthers(t *testing.T) {
+	h := rikkatest.New(t)
+	slow := &blockingCmd{started: make(chan struct{}), release: make(chan struct{})}
+	h.Register(slow, &echoCmd{Rikka: h.Rikka})
+
+	done := make(chan rikkatest.Replies, 1)
+	go func() { done <- h.Send("r.slow") }()
+	<-slow.started
+
+	// DispatchEvent locks each registration like disgord does, so this only
+	// returns if the slow command isn't holding the command registration.
+	replies := make(chan rikkatest.Replies, 1)
+	go func() { replies <- h.Send("r.echo hi") }()
+	select {
+	case r := <-replies:
+		h.ExpectReply(r, "hi")
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow command blocked other commands")
+	}
+
+	close(slow.release)
+	h.ExpectReply(<-done, "done")
+}
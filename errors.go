@@ -0,0 +1,118 @@
+package rikka
+
+import (
+	"context"
+	"fmt"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// ErrorKind classifies errors caused by users.
+type ErrorKind int
+
+const (
+	ErrorKindBadInput ErrorKind = iota
+	ErrorKindPermission
+	ErrorKindNotFound
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindPermission:
+		return "permission"
+	case ErrorKindNotFound:
+		return "not_found"
+	default:
+		return "bad_input"
+	}
+}
+
+func (k ErrorKind) title() string {
+	switch k {
+	case ErrorKindPermission:
+		return "Missing permission"
+	case ErrorKindNotFound:
+		return "Not found"
+	default:
+		return "Invalid input"
+	}
+}
+
+func (k ErrorKind) color() int {
+	switch k {
+	case ErrorKindPermission:
+		return 0xe67e22
+	case ErrorKindNotFound:
+		return 0x95a5a6
+	default:
+		return 0xf1c40f
+	}
+}
+
+// UserError is an error caused by the user. Its message is shown to them as
+// is, so it must not contain any internal details.
+type UserError struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *UserError) Error() string {
+	return e.Message
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+// BadInput returns a UserError for invalid arguments.
+func BadInput(format string, args ...interface{}) error {
+	return &UserError{Kind: ErrorKindBadInput, Message: fmt.Sprintf(format, args...)}
+}
+
+// PermissionDenied returns a UserError for missing permissions.
+func PermissionDenied(format string, args ...interface{}) error {
+	return &UserError{Kind: ErrorKindPermission, Message: fmt.Sprintf(format, args...)}
+}
+
+// NotFound returns a UserError for something the user referred to that does
+// not exist.
+func NotFound(format string, args ...interface{}) error {
+	return &UserError{Kind: ErrorKindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// HandleError reports err to the channel msg was sent in. User errors are
+// shown as is. Any other error is treated as internal: it is logged along with
+// errMsg and a correlation id, and only errMsg and the id are shown.
+func (r *Rikka) HandleError(ctx context.Context, s disgord.Session, msg *disgord.Message, err error, errMsg string) {
+	var embed *disgord.Embed
+
+	var uerr *UserError
+	if xerrors.As(err, &uerr) {
+		r.Log.Debug(ctx, errMsg, slog.Error(err))
+		countCommandError(ctx, uerr.Kind.String())
+		setAuditOutcome(ctx, uerr.Kind.String())
+		embed = &disgord.Embed{
+			Title:       uerr.Kind.title(),
+			Description: uerr.Message,
+			Color:       uerr.Kind.color(),
+		}
+	} else {
+		id := newErrorID()
+		r.Log.Error(ctx, errMsg, slog.Error(err), slog.F("error_id", id))
+		countCommandError(ctx, "internal")
+		setAuditOutcome(ctx, AuditOutcomeInternal)
+		embed = &disgord.Embed{
+			Title:       errMsg,
+			Description: "Something went wrong on our end. If this keeps happening, please report error `" + id + "`.",
+			Color:       0xe74c3c,
+		}
+	}
+
+	_, err = s.SendMsg(ctx, msg.ChannelID, disgord.CreateMessageParams{Embed: embed})
+	if err != nil {
+		r.Log.Error(ctx, "failed to send error message to channel", slog.Error(err))
+	}
+}
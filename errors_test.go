@@ -0,0 +1,23 @@
+package rikka
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestUserErrorAs(t *testing.T) {
+	err := xerrors.Errorf("failed to set prefixes: %w", BadInput("At most %d prefixes may be set", 5))
+
+	var uerr *UserError
+	if !xerrors.As(err, &uerr) {
+		t.Fatal("wrapped user error not found")
+	}
+	if uerr.Kind != ErrorKindBadInput || uerr.Message != "At most 5 prefixes may be set" {
+		t.Errorf("unexpected user error: %+v", uerr)
+	}
+
+	if xerrors.As(xerrors.New("fdb: transaction too old"), &uerr) {
+		t.Error("internal error treated as user error")
+	}
+}
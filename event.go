@@ -0,0 +1,142 @@
+package rikka
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// Event is a common view of any gateway event: where it happened and who
+// caused it. Fields that do not apply to the event are left zero.
+type Event struct {
+	Ctx     context.Context
+	Session disgord.Session
+	// Raw is the disgord event, e.g. *disgord.MessageCreate.
+	Raw interface{}
+
+	GuildID   disgord.Snowflake
+	ChannelID disgord.Snowflake
+	UserID    disgord.Snowflake
+	// Author is the user who caused the event, if the event includes it.
+	Author *disgord.User
+	// Member is the member who caused the event, if the event includes it.
+	Member *disgord.Member
+	// Message is the message the event is about, if the event includes it.
+	Message *disgord.Message
+
+	guildResolved bool
+}
+
+var (
+	messageType = reflect.TypeOf((*disgord.Message)(nil))
+	memberType  = reflect.TypeOf((*disgord.Member)(nil))
+	userType    = reflect.TypeOf((*disgord.User)(nil))
+	guildType   = reflect.TypeOf((*disgord.Guild)(nil))
+	channelType = reflect.TypeOf((*disgord.Channel)(nil))
+	idType      = reflect.TypeOf(disgord.Snowflake(0))
+)
+
+// NewEvent builds the Event for any disgord event by looking at the fields
+// disgord events share: Ctx, Message, Member, User, Guild, Channel, GuildID,
+// ChannelID and UserID. s may be nil.
+func NewEvent(s disgord.Session, evt interface{}) *Event {
+	e := &Event{Ctx: context.Background(), Session: s, Raw: evt}
+
+	v := reflect.ValueOf(evt)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return e
+	}
+	v = v.Elem()
+
+	if ctx, ok := eventField(v, "Ctx", contextType).(context.Context); ok && ctx != nil {
+		e.Ctx = ctx
+	}
+
+	if msg, ok := eventField(v, "Message", messageType).(*disgord.Message); ok && msg != nil {
+		e.Message = msg
+		e.GuildID = msg.GuildID
+		e.ChannelID = msg.ChannelID
+		e.setAuthor(msg.Author)
+		if msg.Member != nil {
+			e.Member = msg.Member
+		}
+	}
+
+	if m, ok := eventField(v, "Member", memberType).(*disgord.Member); ok && m != nil {
+		e.Member = m
+		e.setAuthor(m.User)
+		if !m.GuildID.IsZero() {
+			e.GuildID = m.GuildID
+		}
+	}
+
+	if u, ok := eventField(v, "User", userType).(*disgord.User); ok {
+		e.setAuthor(u)
+	}
+
+	if g, ok := eventField(v, "Guild", guildType).(*disgord.Guild); ok && g != nil {
+		e.GuildID = g.ID
+	}
+
+	if c, ok := eventField(v, "Channel", channelType).(*disgord.Channel); ok && c != nil {
+		e.ChannelID = c.ID
+		e.GuildID = c.GuildID
+	}
+
+	for name, dst := range map[string]*disgord.Snowflake{
+		"GuildID":   &e.GuildID,
+		"ChannelID": &e.ChannelID,
+		"UserID":    &e.UserID,
+	} {
+		if id, ok := eventField(v, name, idType).(disgord.Snowflake); ok && !id.IsZero() {
+			*dst = id
+		}
+	}
+
+	return e
+}
+
+func (e *Event) setAuthor(u *disgord.User) {
+	if u == nil {
+		return
+	}
+
+	e.Author = u
+	e.UserID = u.ID
+}
+
+// eventField returns the field name of v if it has type typ.
+func eventField(v reflect.Value, name string, typ reflect.Type) interface{} {
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanInterface() || f.Type() != typ {
+		return nil
+	}
+
+	return f.Interface()
+}
+
+// Guild returns the guild the event happened in, looking it up from the
+// channel for events that do not include it, e.g. reactions. It returns zero
+// for direct messages.
+func (e *Event) Guild() (disgord.Snowflake, error) {
+	if e.guildResolved || !e.GuildID.IsZero() || e.ChannelID.IsZero() || e.Session == nil {
+		return e.GuildID, nil
+	}
+
+	ch, err := e.Session.GetChannel(e.Ctx, e.ChannelID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get channel: %w", err)
+	}
+
+	e.GuildID = ch.GuildID
+	e.guildResolved = true
+
+	return e.GuildID, nil
+}
+
+// Middleware inspects an event before it is handled and returns false to drop
+// it. Middlewares can be passed to Rikka.On before the handlers they guard,
+// and returned by CommandMiddlewarer.
+type Middleware func(e *Event) bool
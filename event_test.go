@@ -0,0 +1,43 @@
+package rikka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+)
+
+func TestNewEvent(t *testing.T) {
+	ctx := context.WithValue(context.Background(), commandKey{}, "test")
+	user := &disgord.User{ID: 3}
+
+	tests := []struct {
+		name                string
+		evt                 interface{}
+		guild, channel, uid disgord.Snowflake
+	}{
+		{"message create", &disgord.MessageCreate{Ctx: ctx, Message: &disgord.Message{GuildID: 1, ChannelID: 2, Author: user}}, 1, 2, 3},
+		{"message delete", &disgord.MessageDelete{Ctx: ctx, GuildID: 1, ChannelID: 2}, 1, 2, 0},
+		{"reaction add", &disgord.MessageReactionAdd{Ctx: ctx, ChannelID: 2, UserID: 3}, 0, 2, 3},
+		{"member add", &disgord.GuildMemberAdd{Ctx: ctx, Member: &disgord.Member{GuildID: 1, User: user}}, 1, 0, 3},
+		{"member remove", &disgord.GuildMemberRemove{Ctx: ctx, GuildID: 1, User: user}, 1, 0, 3},
+		{"guild create", &disgord.GuildCreate{Ctx: ctx, Guild: &disgord.Guild{ID: 1}}, 1, 0, 0},
+		{"channel create", &disgord.ChannelCreate{Ctx: ctx, Channel: &disgord.Channel{ID: 2, GuildID: 1}}, 1, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEvent(nil, tt.evt)
+			if e.GuildID != tt.guild || e.ChannelID != tt.channel || e.UserID != tt.uid {
+				t.Errorf("got guild %d channel %d user %d, want %d %d %d", e.GuildID, e.ChannelID, e.UserID, tt.guild, tt.channel, tt.uid)
+			}
+			if command, _ := commandFromContext(e.Ctx); command != "test" {
+				t.Error("event context not used")
+			}
+		})
+	}
+
+	if e := NewEvent(nil, nil); e.Ctx == nil {
+		t.Error("nil event has no context")
+	}
+}
@@ -0,0 +1,458 @@
+// Package fake implements a disgord.Session in memory, so the bot can be run
+// without connecting to Discord, e.g. in tests and the console.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// ErrNotFound is returned by Session for anything that was not added to it.
+var ErrNotFound = xerrors.New("not found")
+
+// Message is a message sent through a Session.
+type Message struct {
+	*disgord.Message
+	Files     []File
+	Reactions []Reaction
+	Deleted   bool
+}
+
+// Text returns the content of m followed by the text of its embeds, for
+// matching against.
+func (m *Message) Text() string {
+	parts := []string{m.Content}
+	for _, e := range m.Embeds {
+		parts = append(parts, e.Title, e.Description)
+		for _, f := range e.Fields {
+			parts = append(parts, f.Name, f.Value)
+		}
+		if e.Footer != nil {
+			parts = append(parts, e.Footer.Text)
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Reaction is a reaction added to a message.
+type Reaction struct {
+	Emoji  string
+	UserID disgord.Snowflake
+}
+
+// HasReaction returns true if userID reacted to m with emoji.
+func (m *Message) HasReaction(emoji string, userID disgord.Snowflake) bool {
+	for _, e := range m.Reactions {
+		if e.Emoji == emoji && e.UserID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// File is a file attached to a sent message.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Session is a fake disgord.Session. It serves the guilds, channels, users and
+// members added to it and records the messages sent through it. Methods it
+// does not fake panic.
+type Session struct {
+	disgord.Session
+
+	mu       sync.Mutex
+	self     *disgord.User
+	guilds   map[disgord.Snowflake]*disgord.Guild
+	channels map[disgord.Snowflake]*disgord.Channel
+	users    map[disgord.Snowflake]*disgord.User
+	members  map[disgord.Snowflake]map[disgord.Snowflake]*disgord.Member
+	messages map[disgord.Snowflake]*Message
+	sent     []*Message
+	nextID   disgord.Snowflake
+}
+
+// NewSession returns a Session logged in as self.
+func NewSession(self *disgord.User) *Session {
+	s := &Session{
+		self:     self,
+		guilds:   map[disgord.Snowflake]*disgord.Guild{},
+		channels: map[disgord.Snowflake]*disgord.Channel{},
+		users:    map[disgord.Snowflake]*disgord.User{},
+		members:  map[disgord.Snowflake]map[disgord.Snowflake]*disgord.Member{},
+		messages: map[disgord.Snowflake]*Message{},
+		nextID:   1 << 40,
+	}
+	s.AddUser(self)
+
+	return s
+}
+
+// NewID returns a snowflake not used by anything else in the session.
+func (s *Session) NewID() disgord.Snowflake {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.newID()
+}
+
+func (s *Session) newID() disgord.Snowflake {
+	s.nextID++
+	return s.nextID
+}
+
+// AddGuild adds a guild along with its channels and members.
+func (s *Session) AddGuild(g *disgord.Guild) {
+	s.mu.Lock()
+	s.guilds[g.ID] = g
+	s.mu.Unlock()
+
+	for _, e := range g.Channels {
+		e.GuildID = g.ID
+		s.AddChannel(e)
+	}
+	for _, e := range g.Members {
+		s.addMember(g.ID, e, false)
+	}
+}
+
+// AddChannel adds a channel.
+func (s *Session) AddChannel(c *disgord.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels[c.ID] = c
+}
+
+// AddUser adds a user.
+func (s *Session) AddUser(u *disgord.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.ID] = u
+}
+
+// AddMember adds a member to a guild that was already added.
+func (s *Session) AddMember(guildID disgord.Snowflake, m *disgord.Member) {
+	s.addMember(guildID, m, true)
+}
+
+func (s *Session) addMember(guildID disgord.Snowflake, m *disgord.Member, appendToGuild bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.GuildID = guildID
+	if s.members[guildID] == nil {
+		s.members[guildID] = map[disgord.Snowflake]*disgord.Member{}
+	}
+	s.members[guildID][m.User.ID] = m
+	s.users[m.User.ID] = m.User
+
+	if g, ok := s.guilds[guildID]; ok && appendToGuild {
+		g.Members = append(g.Members, m)
+	}
+}
+
+// AddMessage adds a message as if it was sent by someone else, so it can be
+// fetched.
+func (s *Session) AddMessage(m *disgord.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[m.ID] = &Message{Message: m}
+}
+
+// Sent returns every message sent through the session, in order.
+func (s *Session) Sent() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Message(nil), s.sent...)
+}
+
+func (s *Session) SendMsg(ctx context.Context, channelID disgord.Snowflake, data ...interface{}) (*disgord.Message, error) {
+	params := &disgord.CreateMessageParams{}
+	for _, e := range data {
+		switch e := e.(type) {
+		case nil:
+		case *disgord.CreateMessageParams:
+			*params = *e
+		case disgord.CreateMessageParams:
+			*params = e
+		case disgord.CreateMessageFileParams:
+			params.Files = append(params.Files, e)
+		case *disgord.CreateMessageFileParams:
+			params.Files = append(params.Files, *e)
+		case disgord.Embed:
+			params.Embed = &e
+		case *disgord.Embed:
+			params.Embed = e
+		case string:
+			params.Content = strings.TrimPrefix(params.Content+" "+e, " ")
+		default:
+			params.Content = strings.TrimPrefix(params.Content+" "+fmt.Sprint(e), " ")
+		}
+	}
+
+	return s.CreateMessage(ctx, channelID, params)
+}
+
+func (s *Session) CreateMessage(ctx context.Context, channelID disgord.Snowflake, params *disgord.CreateMessageParams, flags ...disgord.Flag) (*disgord.Message, error) {
+	if params.Content == "" && params.Embed == nil && len(params.Files) == 0 {
+		return nil, xerrors.New("cannot send an empty message")
+	}
+
+	var files []File
+	for _, e := range params.Files {
+		data, err := ioutil.ReadAll(e.Reader)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read file %s: %w", e.FileName, err)
+		}
+		files = append(files, File{Name: e.FileName, Data: data})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.channels[channelID]
+	if !ok {
+		return nil, xerrors.Errorf("channel %d: %w", channelID, ErrNotFound)
+	}
+
+	msg := &disgord.Message{
+		ID:        s.newID(),
+		ChannelID: channelID,
+		GuildID:   ch.GuildID,
+		Author:    s.self,
+		Content:   params.Content,
+		Timestamp: disgord.Time{Time: time.Now()},
+	}
+	if params.Embed != nil {
+		msg.Embeds = []*disgord.Embed{params.Embed}
+	}
+
+	m := &Message{Message: msg, Files: files}
+	s.messages[msg.ID] = m
+	s.sent = append(s.sent, m)
+
+	return msg, nil
+}
+
+func (s *Session) GetMessage(ctx context.Context, channelID, messageID disgord.Snowflake, flags ...disgord.Flag) (*disgord.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok || m.Deleted || m.ChannelID != channelID {
+		return nil, xerrors.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+
+	return m.Message, nil
+}
+
+func (s *Session) SetMsgContent(ctx context.Context, channelID, messageID disgord.Snowflake, content string) (*disgord.Message, error) {
+	return s.editMessage(channelID, messageID, func(m *disgord.Message) { m.Content = content })
+}
+
+func (s *Session) SetMsgEmbed(ctx context.Context, channelID, messageID disgord.Snowflake, embed *disgord.Embed) (*disgord.Message, error) {
+	return s.editMessage(channelID, messageID, func(m *disgord.Message) {
+		m.Embeds = nil
+		if embed != nil {
+			m.Embeds = []*disgord.Embed{embed}
+		}
+	})
+}
+
+func (s *Session) editMessage(channelID, messageID disgord.Snowflake, edit func(m *disgord.Message)) (*disgord.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok || m.Deleted || m.ChannelID != channelID {
+		return nil, xerrors.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+	if m.Author == nil || m.Author.ID != s.self.ID {
+		return nil, xerrors.New("cannot edit a message sent by another user")
+	}
+
+	edit(m.Message)
+	m.EditedTimestamp = disgord.Time{Time: time.Now()}
+
+	return m.Message, nil
+}
+
+func (s *Session) DeleteMessage(ctx context.Context, channelID, messageID disgord.Snowflake, flags ...disgord.Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok || m.Deleted || m.ChannelID != channelID {
+		return xerrors.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+	m.Deleted = true
+
+	return nil
+}
+
+// Reactions returns the reactions on a message. Unlike Message.Reactions it
+// is safe to call while the bot is handling events.
+func (s *Session) Reactions(messageID disgord.Snowflake) []Reaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok {
+		return nil
+	}
+
+	return append([]Reaction(nil), m.Reactions...)
+}
+
+func (s *Session) CreateReaction(ctx context.Context, channelID, messageID disgord.Snowflake, emoji interface{}, flags ...disgord.Flag) error {
+	return s.AddReaction(channelID, messageID, s.self.ID, emojiName(emoji))
+}
+
+// AddReaction adds a reaction from userID to a message, as if the user reacted
+// to it. It does not dispatch an event.
+func (s *Session) AddReaction(channelID, messageID, userID disgord.Snowflake, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok || m.Deleted || m.ChannelID != channelID {
+		return xerrors.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+	if !m.HasReaction(emoji, userID) {
+		m.Reactions = append(m.Reactions, Reaction{Emoji: emoji, UserID: userID})
+	}
+
+	return nil
+}
+
+func (s *Session) DeleteOwnReaction(ctx context.Context, channelID, messageID disgord.Snowflake, emoji interface{}, flags ...disgord.Flag) error {
+	return s.DeleteUserReaction(ctx, channelID, messageID, s.self.ID, emoji, flags...)
+}
+
+func (s *Session) DeleteUserReaction(ctx context.Context, channelID, messageID, userID disgord.Snowflake, emoji interface{}, flags ...disgord.Flag) error {
+	name := emojiName(emoji)
+	return s.removeReactions(channelID, messageID, func(r Reaction) bool {
+		return r.Emoji == name && r.UserID == userID
+	})
+}
+
+func (s *Session) DeleteAllReactions(ctx context.Context, channelID, messageID disgord.Snowflake, flags ...disgord.Flag) error {
+	return s.removeReactions(channelID, messageID, func(Reaction) bool { return true })
+}
+
+func (s *Session) removeReactions(channelID, messageID disgord.Snowflake, remove func(r Reaction) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageID]
+	if !ok || m.Deleted || m.ChannelID != channelID {
+		return xerrors.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+
+	kept := m.Reactions[:0]
+	for _, e := range m.Reactions {
+		if !remove(e) {
+			kept = append(kept, e)
+		}
+	}
+	m.Reactions = kept
+
+	return nil
+}
+
+// emojiName returns the name of an emoji passed to the reaction methods.
+func emojiName(emoji interface{}) string {
+	switch e := emoji.(type) {
+	case *disgord.Emoji:
+		return e.Name
+	default:
+		return fmt.Sprint(e)
+	}
+}
+
+func (s *Session) GetChannel(ctx context.Context, id disgord.Snowflake, flags ...disgord.Flag) (*disgord.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[id]
+	if !ok {
+		return nil, xerrors.Errorf("channel %d: %w", id, ErrNotFound)
+	}
+
+	return c, nil
+}
+
+func (s *Session) GetGuild(ctx context.Context, id disgord.Snowflake, flags ...disgord.Flag) (*disgord.Guild, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.guilds[id]
+	if !ok {
+		return nil, xerrors.Errorf("guild %d: %w", id, ErrNotFound)
+	}
+
+	return g, nil
+}
+
+func (s *Session) GetGuildRoles(ctx context.Context, guildID disgord.Snowflake, flags ...disgord.Flag) ([]*disgord.Role, error) {
+	g, err := s.GetGuild(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Roles, nil
+}
+
+func (s *Session) GetMember(ctx context.Context, guildID, userID disgord.Snowflake, flags ...disgord.Flag) (*disgord.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[guildID][userID]
+	if !ok {
+		return nil, xerrors.Errorf("member %d in guild %d: %w", userID, guildID, ErrNotFound)
+	}
+
+	return m, nil
+}
+
+func (s *Session) GetUser(ctx context.Context, id disgord.Snowflake, flags ...disgord.Flag) (*disgord.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, xerrors.Errorf("user %d: %w", id, ErrNotFound)
+	}
+
+	return u, nil
+}
+
+func (s *Session) GetCurrentUser(ctx context.Context, flags ...disgord.Flag) (*disgord.User, error) {
+	return s.self, nil
+}
+
+func (s *Session) GetConnectedGuilds() []disgord.Snowflake {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]disgord.Snowflake, 0, len(s.guilds))
+	for id := range s.guilds {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
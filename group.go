@@ -0,0 +1,195 @@
+package rikka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andersfylling/disgord"
+)
+
+// Subcommand is a command nested in a Group. A subcommand either has a Handle
+// func, which is called with its parsed Args, or Subcommands of its own.
+type Subcommand struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Args        ArgSpec
+	// Permissions are required in addition to those of the parent commands.
+	Permissions disgord.PermissionBits
+
+	Handle      func(s disgord.Session, mc *disgord.MessageCreate, vals ArgValues)
+	Subcommands []Subcommand
+}
+
+func (c Subcommand) matches(name string) bool {
+	if strings.EqualFold(c.Name, name) {
+		return true
+	}
+
+	for _, e := range c.Aliases {
+		if strings.EqualFold(e, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// usage returns the usage of c, e.g. "<subcommand> ..." or "<user> [reason]".
+func (c Subcommand) usage() string {
+	if len(c.Subcommands) > 0 {
+		return "<" + strings.Join(subcommandNames(c.Subcommands), " | ") + "> ..."
+	}
+
+	return c.Args.Usage()
+}
+
+// Group is a Command made of a tree of subcommands, e.g. `log messages delete
+// enable`. Messages are routed down the tree one word at a time, checking the
+// permissions and overrides of each subcommand on the way, until a subcommand
+// with a Handle func is found. Words that don't match a subcommand are
+// answered with the valid options and the closest matches.
+//
+// Commands that need passive listeners or a module embed *Group and
+// implement Register or Module themselves.
+type Group struct {
+	r    *Rikka
+	help CommandHelp
+	root Subcommand
+}
+
+// NewGroup returns a command with help as its top level help. Usage and the
+// list of subcommands in the detailed help are generated from subs.
+func NewGroup(r *Rikka, help CommandHelp, subs ...Subcommand) *Group {
+	return &Group{
+		r:    r,
+		help: help,
+		root: Subcommand{Name: help.Name, Subcommands: subs},
+	}
+}
+
+func (g *Group) Register(fn func(event string, inputs ...interface{})) {}
+
+func (g *Group) Help() []CommandHelp {
+	h := g.help
+	if h.Usage == "" {
+		h.Usage = g.root.usage()
+	}
+
+	var lines []string
+	walkSubcommands(g.root.Subcommands, nil, func(path []string, c Subcommand) {
+		line := "`" + strings.TrimSpace(strings.Join(path, " ")+" "+c.Args.Usage()) + "`"
+		if c.Description != "" {
+			line += " - " + c.Description
+		}
+		lines = append(lines, line)
+	})
+
+	subs := "**Subcommands**\n" + strings.Join(lines, "\n")
+	if h.Detailed != "" {
+		subs = h.Detailed + "\n\n" + subs
+	}
+	h.Detailed = subs
+
+	return []CommandHelp{h}
+}
+
+func (g *Group) Handle(s disgord.Session, mc *disgord.MessageCreate, args Args) {
+	var (
+		ctx  = mc.Ctx
+		node = g.root
+		path = []string{g.help.Name}
+	)
+
+	for len(node.Subcommands) > 0 {
+		if len(args) == 0 {
+			g.r.HandleError(ctx, s, mc.Message, BadInput("`%s` needs a subcommand: %s",
+				strings.Join(path, " "), formatSubcommands(node.Subcommands)), "Missing subcommand")
+			return
+		}
+
+		name := args.Pop()
+		next, ok := findSubcommand(node.Subcommands, name)
+		if !ok {
+			msg := fmt.Sprintf("Unknown subcommand `%s` for `%s`, expected one of %s",
+				name, strings.Join(path, " "), formatSubcommands(node.Subcommands))
+			if suggestions := Suggest(name, subcommandNamesAndAliases(node.Subcommands)); len(suggestions) > 0 {
+				msg += fmt.Sprintf("\nDid you mean %s?", quoteNames(suggestions, "or"))
+			}
+
+			g.r.HandleError(ctx, s, mc.Message, BadInput("%s", msg), "Unknown subcommand")
+			return
+		}
+		node = next
+		path = append(path, node.Name)
+		setAuditCommand(ctx, strings.Join(path, " "), args)
+
+		// Subcommands are checked like commands, with their path, e.g.
+		// "tag admin purge", as the name overrides are set for.
+		allowed, reason, err := g.r.canRun(ctx, s, mc.Message, CommandHelp{
+			Name:        strings.Join(path, " "),
+			Permissions: node.Permissions,
+		})
+		if err != nil {
+			g.r.HandleError(ctx, s, mc.Message, err, "Failed to check permissions")
+			return
+		}
+		if !allowed {
+			g.r.HandleError(ctx, s, mc.Message, PermissionDenied(reason), "Permission denied")
+			return
+		}
+	}
+
+	vals, ok := g.r.ParseArgs(s, mc, strings.Join(path, " "), node.Args, args)
+	if !ok {
+		return
+	}
+
+	node.Handle(s, mc, vals)
+}
+
+func findSubcommand(subs []Subcommand, name string) (Subcommand, bool) {
+	for _, e := range subs {
+		if e.matches(name) {
+			return e, true
+		}
+	}
+
+	return Subcommand{}, false
+}
+
+// walkSubcommands calls fn with every subcommand that has a Handle func and
+// the names leading to it.
+func walkSubcommands(subs []Subcommand, path []string, fn func(path []string, c Subcommand)) {
+	for _, e := range subs {
+		p := append(append([]string(nil), path...), e.Name)
+		if len(e.Subcommands) > 0 {
+			walkSubcommands(e.Subcommands, p, fn)
+			continue
+		}
+		fn(p, e)
+	}
+}
+
+func subcommandNames(subs []Subcommand) []string {
+	names := make([]string, len(subs))
+	for i, e := range subs {
+		names[i] = e.Name
+	}
+
+	return names
+}
+
+func subcommandNamesAndAliases(subs []Subcommand) []string {
+	var names []string
+	for _, e := range subs {
+		names = append(names, e.Name)
+		names = append(names, e.Aliases...)
+	}
+
+	return names
+}
+
+func formatSubcommands(subs []Subcommand) string {
+	return fmt.Sprintf("`%s`", strings.Join(subcommandNames(subs), "`, `"))
+}
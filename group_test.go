@@ -0,0 +1,129 @@
+package rikka_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestGroup(t *testing.T) {
+	h := rikkatest.New(t)
+
+	reply := func(text string) func(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+		return func(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+			s.SendMsg(mc.Ctx, mc.Message.ChannelID, text+" "+vals.String("name"))
+		}
+	}
+
+	group := rikka.NewGroup(h.Rikka, rikka.CommandHelp{Name: "tag", Description: "Manage tags"},
+		rikka.Subcommand{Name: "show", Aliases: []string{"get"}, Args: rikka.ArgSpec{{Name: "name", Type: rikka.ArgString}}, Handle: reply("showing")},
+		rikka.Subcommand{Name: "admin", Subcommands: []rikka.Subcommand{
+			{Name: "purge", Permissions: disgord.PermissionManageMessages, Description: "Delete every tag", Handle: reply("purged")},
+		}},
+	)
+	h.Register(group)
+
+	h.ExpectReply(h.Send("r.tag show hello"), "showing hello")
+	h.ExpectReply(h.Send("r.tag GET hello"), "showing hello")
+	h.ExpectReply(h.Send("r.tag show"), "Usage: `r.tag show <name>`")
+	h.ExpectReply(h.Send("r.tag"), "`tag` needs a subcommand: `show`, `admin`")
+	h.ExpectReply(h.Send("r.tag nope"), "Unknown subcommand `nope` for `tag`, expected one of `show`, `admin`")
+	h.ExpectReply(h.Send("r.tag admin nope"), "expected one of `purge`")
+	h.ExpectReply(h.Send("r.tag shwo"), "Did you mean `show`?")
+	h.ExpectReply(h.Send("r.tag gte"), "Did you mean `get`?")
+	h.ExpectReply(h.Send("r.tag admin purge"), "Manage Messages permission to use `tag admin purge`")
+	h.ExpectReply(h.SendAs(h.Owner, "r.tag admin purge"), "purged")
+
+	// The audit log records the subcommands separately from their arguments.
+	entries, err := h.Rikka.AuditLog(h.Guild.ID, rikka.AuditFilter{Command: "tag show"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || entries[len(entries)-1].Command != "tag show" || entries[len(entries)-1].Args != "hello" {
+		t.Errorf("audit entries = %+v, want `tag show` with `hello`", entries)
+	}
+
+	help := group.Help()[0]
+	if help.Usage != "<show | admin> ..." {
+		t.Errorf("usage = %q", help.Usage)
+	}
+	if !strings.Contains(help.Detailed, "`show <name>`") || !strings.Contains(help.Detailed, "`admin purge` - Delete every tag") {
+		t.Errorf("detailed help is missing subcommands:\n%s", help.Detailed)
+	}
+}
+
+func TestGroupPermissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides []rikka.PermissionOverride
+		want      string
+	}{
+		{name: "missing permission", want: "Manage Messages permission to use `tag admin purge`"},
+		{
+			name:      "user allow",
+			overrides: []rikka.PermissionOverride{{Command: "tag admin purge", Target: rikka.PermissionTargetUser, ID: 3, Allow: true}},
+			want:      "purged",
+		},
+		{
+			name:      "everyone role allow",
+			overrides: []rikka.PermissionOverride{{Command: "tag admin purge", Target: rikka.PermissionTargetRole, ID: 10, Allow: true}},
+			want:      "purged",
+		},
+		{
+			name: "user beats channel",
+			overrides: []rikka.PermissionOverride{
+				{Command: "tag admin purge", Target: rikka.PermissionTargetChannel, ID: 20},
+				{Command: "tag admin purge", Target: rikka.PermissionTargetUser, ID: 3, Allow: true},
+			},
+			want: "purged",
+		},
+		{
+			name: "channel beats role",
+			overrides: []rikka.PermissionOverride{
+				{Command: "tag admin purge", Target: rikka.PermissionTargetRole, ID: 10, Allow: true},
+				{Command: "tag admin purge", Target: rikka.PermissionTargetChannel, ID: 20},
+			},
+			want: "You are not allowed to use `tag admin purge` here",
+		},
+		{
+			name: "denied parent",
+			overrides: []rikka.PermissionOverride{
+				{Command: "tag admin", Target: rikka.PermissionTargetUser, ID: 3},
+				{Command: "tag admin purge", Target: rikka.PermissionTargetUser, ID: 3, Allow: true},
+			},
+			want: "You are not allowed to use `tag admin` here",
+		},
+		{
+			name:      "override of the bare name",
+			overrides: []rikka.PermissionOverride{{Command: "purge", Target: rikka.PermissionTargetUser, ID: 3, Allow: true}},
+			want:      "Manage Messages permission to use `tag admin purge`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := rikkatest.New(t)
+			h.Register(rikka.NewGroup(h.Rikka, rikka.CommandHelp{Name: "tag"},
+				rikka.Subcommand{Name: "admin", Subcommands: []rikka.Subcommand{{
+					Name:        "purge",
+					Permissions: disgord.PermissionManageMessages,
+					Handle: func(s disgord.Session, mc *disgord.MessageCreate, vals rikka.ArgValues) {
+						s.SendMsg(mc.Ctx, mc.Message.ChannelID, "purged")
+					},
+				}}},
+			))
+
+			for _, e := range tt.overrides {
+				if err := h.Rikka.SetPermissionOverride(h.Guild.ID, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h.ExpectReply(h.Send("r.tag admin purge"), tt.want)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package rikka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"golang.org/x/xerrors"
+)
+
+// HealthCheckTimeout bounds how long /readyz waits for all checks.
+const HealthCheckTimeout = 5 * time.Second
+
+// HealthCheck returns an error if a dependency of the bot is unavailable.
+type HealthCheck func(ctx context.Context) error
+
+type health struct {
+	mu     sync.RWMutex
+	ready  bool
+	checks map[string]HealthCheck
+}
+
+// AddHealthCheck registers a check that must pass for the bot to be ready.
+// Registering a check under an existing name replaces it.
+func (r *Rikka) AddHealthCheck(name string, check HealthCheck) {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+
+	if r.health.checks == nil {
+		r.health.checks = map[string]HealthCheck{}
+	}
+	r.health.checks[name] = check
+}
+
+func (r *Rikka) setReady(ready bool) {
+	r.health.mu.Lock()
+	r.health.ready = ready
+	r.health.mu.Unlock()
+}
+
+// gatewayLogger forwards the gateway's logs to the bot's logger. disgord
+// doesn't emit an event when the connection drops, only this log line, so it
+// also clears readiness until the next READY or RESUMED.
+type gatewayLogger struct {
+	r *Rikka
+}
+
+func (l gatewayLogger) Debug(v ...interface{}) {
+	l.r.Log.Debug(l.r.ctx, gatewayMessage(v))
+}
+
+func (l gatewayLogger) Info(v ...interface{}) {
+	// disgord v0.16 logs "disconnected" as the last value when a shard's
+	// connection closes (internal/gateway/client.go disconnect), including
+	// before every reconnect. There is no event for it, so this match has to
+	// be checked when upgrading disgord.
+	if len(v) > 0 && strings.EqualFold(fmt.Sprint(v[len(v)-1]), "disconnected") {
+		l.r.setReady(false)
+	}
+	l.r.Log.Info(l.r.ctx, gatewayMessage(v))
+}
+
+func (l gatewayLogger) Error(v ...interface{}) {
+	l.r.Log.Error(l.r.ctx, gatewayMessage(v))
+}
+
+func gatewayMessage(v []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// addDefaultHealthChecks registers the checks every bot has.
+func (r *Rikka) addDefaultHealthChecks() {
+	r.AddHealthCheck("gateway", func(ctx context.Context) error {
+		r.health.mu.RLock()
+		defer r.health.mu.RUnlock()
+
+		if !r.health.ready {
+			return xerrors.New("READY not received")
+		}
+		return nil
+	})
+
+	r.AddHealthCheck("storage", r.store.Ping)
+
+	r.AddHealthCheck("commands", func(ctx context.Context) error {
+		if _, rtr := r.registered(); len(rtr) == 0 {
+			return xerrors.New("no commands registered")
+		}
+		return nil
+	})
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// runHealthChecks runs every check concurrently and reports whether all of
+// them passed.
+func (r *Rikka) runHealthChecks(ctx context.Context) (map[string]checkResult, bool) {
+	r.health.mu.RLock()
+	names := make([]string, 0, len(r.health.checks))
+	checks := make([]HealthCheck, 0, len(r.health.checks))
+	for name, check := range r.health.checks {
+		names = append(names, name)
+		checks = append(checks, check)
+	}
+	r.health.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			errs[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	var (
+		results = make(map[string]checkResult, len(checks))
+		ok      = true
+	)
+	for i, name := range names {
+		if errs[i] != nil {
+			ok = false
+			results[name] = checkResult{Status: "error", Error: errs[i].Error()}
+			continue
+		}
+		results[name] = checkResult{Status: "ok"}
+	}
+
+	return results, ok
+}
+
+// runCheck runs check, giving up when ctx is done even if check ignores it.
+func runCheck(ctx context.Context, check HealthCheck) error {
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleHealthz reports whether the process is alive. It only fails once
+// shutdown has started.
+func (r *Rikka) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	r.lifecycle.mu.RLock()
+	closing := r.lifecycle.closing
+	r.lifecycle.mu.RUnlock()
+
+	if closing {
+		r.writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "shutting_down"})
+		return
+	}
+
+	r.writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the bot and everything it depends on is
+// working, with the result of every check.
+func (r *Rikka) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	results, ok := r.runHealthChecks(req.Context())
+	if !ok {
+		r.writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Checks: results})
+		return
+	}
+
+	r.writeHealth(w, http.StatusOK, healthResponse{Status: "ok", Checks: results})
+}
+
+func (r *Rikka) writeHealth(w http.ResponseWriter, status int, res healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(res)
+	if err != nil {
+		r.Log.Debug(r.ctx, "failed to write health response", slog.Error(err))
+	}
+}
@@ -0,0 +1,95 @@
+package rikka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cdr.dev/slog/sloggers/slogtest"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestReadyz(t *testing.T) {
+	r := &Rikka{ctx: context.Background()}
+	r.AddHealthCheck("ok", func(ctx context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	r.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	r.AddHealthCheck("broken", func(ctx context.Context) error { return xerrors.New("unreachable") })
+	r.AddHealthCheck("hung", func(ctx context.Context) error { select {} })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rec = httptest.NewRecorder()
+	r.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+
+	var res healthResponse
+	err := json.Unmarshal(rec.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checks["broken"].Error != "unreachable" {
+		t.Errorf("broken check = %+v", res.Checks["broken"])
+	}
+	if res.Checks["hung"].Status != "error" {
+		t.Errorf("hung check = %+v", res.Checks["hung"])
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	r := &Rikka{ctx: context.Background()}
+
+	rec := httptest.NewRecorder()
+	r.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	r.lifecycle.closing = true
+	rec = httptest.NewRecorder()
+	r.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestGatewayReadiness(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+	r.Log = slogtest.Make(t, nil)
+
+	gateway := func() error {
+		r.health.mu.RLock()
+		check := r.health.checks["gateway"]
+		r.health.mu.RUnlock()
+		return check(context.Background())
+	}
+
+	if gateway() == nil {
+		t.Fatal("ready before connecting")
+	}
+
+	r.setReady(true)
+	if err := gateway(); err != nil {
+		t.Fatalf("not ready after READY: %v", err)
+	}
+
+	gatewayLogger{r: r}.Info("[ws-e,s:12,shard:0]", "disconnected")
+	if gateway() == nil {
+		t.Fatal("still ready after disconnecting")
+	}
+}
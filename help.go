@@ -44,40 +44,128 @@ type CommandHelp struct {
 	Aliases     []string
 	Section     HelpSection
 	Description string
+	// Usage is generated from Args if empty.
 	Usage       string
+	Args        ArgSpec
+	Permissions disgord.PermissionBits
+	Cooldowns   []Cooldown
 	Detailed    string
 	Examples    []string
 }
 
-func (r *Rikka) registerHelp(s disgord.Session, mc *disgord.MessageCreate) {
-	if !MatchesCommand(r, "help", mc.Message) {
-		return
+// UsageString returns Usage, or the usage generated from Args if it is empty.
+func (h CommandHelp) UsageString() string {
+	if h.Usage != "" {
+		return h.Usage
+	}
+
+	return h.Args.Usage()
+}
+
+type helpCmd struct {
+	*Rikka
+}
+
+func (c *helpCmd) Register(fn func(event string, inputs ...interface{})) {}
+
+func (c *helpCmd) Help() []CommandHelp {
+	return []CommandHelp{
+		{
+			Name:        "help",
+			Aliases:     []string{"commands"},
+			Section:     HelpSecionGeneral,
+			Description: "View command help",
+			Usage:       "[command | section]",
+			Examples: []string{
+				"`%shelp`            - List all commands.",
+				"`%shelp seen`       - View detailed help for the seen command.",
+				"`%shelp moderation` - List all moderation commands.",
+			},
+		},
 	}
+}
 
+func (c *helpCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args Args) {
 	var (
-		ctx  = mc.Ctx
-		args = ParseCommand(r, mc.Message)
+		ctx    = mc.Ctx
+		prefix = c.Prefixes(mc.Message.GuildID)[0]
+		helps  = c.helps(mc.Message.GuildID)
 	)
 
-	if len(args) > 0 {
-		s.SendMsg(ctx, mc.Message.ChannelID, "Detailed help coming soon :)")
+	if len(args) == 0 {
+		pages := append(EmbedPages{c.embedFromCommandHelp(prefix, helps, HelpSections)}, c.embedsFromSections(prefix, helps)...)
+		err := c.Paginate(ctx, s, mc.Message, pages)
+		if err != nil {
+			c.HandleError(ctx, s, mc.Message, err, "Failed to send help")
+		}
 		return
 	}
 
-	helps := make([]CommandHelp, 0, len(r.cmds))
-	for _, e := range r.cmds {
+	name := args.Pop()
+	for _, e := range helps {
+		if e.matches(name) {
+			s.SendMsg(ctx, mc.Message.ChannelID, disgord.CreateMessageParams{Embed: c.embedFromDetailedHelp(prefix, e)})
+			return
+		}
+	}
+
+	for _, e := range HelpSections {
+		if strings.EqualFold(e.String(), name) {
+			s.SendMsg(ctx, mc.Message.ChannelID, disgord.CreateMessageParams{Embed: c.embedFromCommandHelp(prefix, helps, []HelpSection{e})})
+			return
+		}
+	}
+
+	s.SendMsg(ctx, mc.Message.ChannelID, fmt.Sprintf("No command or section named `%s`. Type `%shelp` for a list of commands.", name, prefix))
+}
+
+// helps returns the help of every registered command whose module is enabled
+// in a guild.
+func (r *Rikka) helps(guildID disgord.Snowflake) []CommandHelp {
+	cmds, _ := r.registered()
+	helps := make([]CommandHelp, 0, len(cmds))
+	for _, e := range cmds {
+		if !r.ModuleEnabled(guildID, commandModule(e)) {
+			continue
+		}
 		helps = append(helps, e.Help()...)
 	}
 
-	s.SendMsg(ctx, mc.Message.ChannelID, disgord.CreateMessageParams{Embed: r.embedFromCommandHelp(helps)})
+	return helps
+}
+
+func (h CommandHelp) matches(name string) bool {
+	if strings.EqualFold(h.Name, name) {
+		return true
+	}
+
+	for _, e := range h.Aliases {
+		if strings.EqualFold(e, name) {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (r *Rikka) embedFromCommandHelp(helps []CommandHelp) *disgord.Embed {
-	av, _ := r.self.AvatarURL(1024, true)
+func (r *Rikka) helpAuthor() *disgord.EmbedAuthor {
+	var av string
+	if r.self != nil {
+		av, _ = r.self.AvatarURL(1024, true)
+	}
+
+	return &disgord.EmbedAuthor{
+		Name:    "Rikka v2 Command Help",
+		IconURL: av,
+		URL:     "https://github.com/coadler/rikka2",
+	}
+}
+
+func (r *Rikka) embedFromCommandHelp(prefix string, helps []CommandHelp, sections []HelpSection) *disgord.Embed {
 	fbuilder := strings.Builder{}
 
-	fields := make([]*disgord.EmbedField, len(HelpSections))
-	for i, sect := range HelpSections {
+	fields := make([]*disgord.EmbedField, 0, len(sections))
+	for _, sect := range sections {
 		for _, e := range helps {
 			if e.Section == sect {
 				if fbuilder.Len() > 0 {
@@ -87,27 +175,122 @@ func (r *Rikka) embedFromCommandHelp(helps []CommandHelp) *disgord.Embed {
 			}
 		}
 
-		fields[i] = &disgord.EmbedField{
+		if fbuilder.Len() == 0 {
+			if len(sections) > 1 {
+				continue
+			}
+			fbuilder.WriteString("No commands")
+		}
+
+		fields = append(fields, &disgord.EmbedField{
 			Name:   sect.String(),
 			Value:  fbuilder.String(),
 			Inline: true,
-		}
+		})
 
 		fbuilder.Reset()
 	}
 
+	author := r.helpAuthor()
 	return &disgord.Embed{
-		Author: &disgord.EmbedAuthor{
-			Name:    "Rikka v2 Command Help",
-			IconURL: av,
-			URL:     "https://github.com/coadler/rikka2",
-		},
+		Author: author,
 		Thumbnail: &disgord.EmbedThumbnail{
-			URL: av,
+			URL: author.IconURL,
 		},
 		Title:       "Join our server for more information",
 		URL:         "https://asdf.com",
-		Description: fmt.Sprintf("Type `%shelp [command]` for detailed usage information", r.Prefix),
+		Description: fmt.Sprintf("Type `%shelp [command]` for detailed usage information", prefix),
+		Fields:      fields,
+	}
+}
+
+// embedsFromSections returns an embed for each section with commands, listing
+// the description of each command in it.
+func (r *Rikka) embedsFromSections(prefix string, helps []CommandHelp) []*disgord.Embed {
+	var embeds []*disgord.Embed
+	for _, sect := range HelpSections {
+		var lines []string
+		for _, e := range helps {
+			if e.Section == sect {
+				lines = append(lines, fmt.Sprintf("`%s%s` - %s", prefix, e.Name, e.Description))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		embeds = append(embeds, &disgord.Embed{
+			Author:      r.helpAuthor(),
+			Title:       sect.String() + " commands",
+			Description: strings.Join(lines, "\n"),
+		})
+	}
+
+	return embeds
+}
+
+func (r *Rikka) embedFromDetailedHelp(prefix string, h CommandHelp) *disgord.Embed {
+	description := h.Description
+	if h.Detailed != "" {
+		description += "\n\n" + h.Detailed
+	}
+
+	fields := []*disgord.EmbedField{
+		{Name: "Usage", Value: strings.TrimSpace(fmt.Sprintf("`%s%s %s`", prefix, h.Name, h.UsageString()))},
+		{Name: "Section", Value: h.Section.String(), Inline: true},
+	}
+
+	if len(h.Aliases) > 0 {
+		fields = append(fields, &disgord.EmbedField{
+			Name:   "Aliases",
+			Value:  "`" + strings.Join(h.Aliases, "`, `") + "`",
+			Inline: true,
+		})
+	}
+
+	if perms := PermissionNames(h.Permissions); len(perms) > 0 {
+		fields = append(fields, &disgord.EmbedField{
+			Name:   "Required permissions",
+			Value:  strings.Join(perms, ", "),
+			Inline: true,
+		})
+	} else if h.Section == HelpSectionOwner {
+		fields = append(fields, &disgord.EmbedField{
+			Name:   "Required permissions",
+			Value:  "Bot owner",
+			Inline: true,
+		})
+	}
+
+	if len(h.Cooldowns) > 0 {
+		cooldowns := make([]string, len(h.Cooldowns))
+		for i, e := range h.Cooldowns {
+			cooldowns[i] = e.String()
+		}
+
+		fields = append(fields, &disgord.EmbedField{
+			Name:   "Cooldown",
+			Value:  strings.Join(cooldowns, "\n"),
+			Inline: true,
+		})
+	}
+
+	if len(h.Examples) > 0 {
+		examples := make([]string, len(h.Examples))
+		for i, e := range h.Examples {
+			examples[i] = strings.ReplaceAll(e, "%s", prefix)
+		}
+
+		fields = append(fields, &disgord.EmbedField{
+			Name:  "Examples",
+			Value: strings.Join(examples, "\n"),
+		})
+	}
+
+	return &disgord.Embed{
+		Author:      r.helpAuthor(),
+		Title:       prefix + h.Name,
+		Description: description,
 		Fields:      fields,
 	}
 }
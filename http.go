@@ -0,0 +1,45 @@
+package rikka
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/xerrors"
+)
+
+// serveHTTP starts the HTTP server for metrics and health checks if HTTPAddr
+// is set. It is stopped during shutdown.
+func (r *Rikka) serveHTTP() error {
+	if r.HTTPAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", r.handleHealthz)
+	mux.HandleFunc("/readyz", r.handleReadyz)
+
+	l, err := net.Listen("tcp", r.HTTPAddr)
+	if err != nil {
+		return xerrors.Errorf("failed to listen on %s: %w", r.HTTPAddr, err)
+	}
+
+	srv := &http.Server{
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := srv.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			r.Log.Error(r.ctx, "http server failed", slog.Error(err))
+		}
+	}()
+	r.OnShutdown(srv.Shutdown)
+
+	r.Log.Info(r.ctx, "serving http", slog.F("addr", l.Addr().String()))
+	return nil
+}
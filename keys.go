@@ -0,0 +1,19 @@
+package rikka
+
+import (
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+// tupleSnowflake converts an unpacked tuple element back into a snowflake.
+// Packed uint64s are unpacked as int64s unless they overflow one.
+func tupleSnowflake(e tuple.TupleElement) disgord.Snowflake {
+	switch v := e.(type) {
+	case int64:
+		return disgord.Snowflake(v)
+	case uint64:
+		return disgord.Snowflake(v)
+	default:
+		return 0
+	}
+}
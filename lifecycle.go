@@ -0,0 +1,371 @@
+package rikka
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// DefaultHandlerTimeout is how long a single event handler may run before
+	// its context is cancelled.
+	DefaultHandlerTimeout = 30 * time.Second
+	// DefaultShutdownTimeout is how long shutdown waits for in-flight
+	// handlers before cancelling them.
+	DefaultShutdownTimeout = 15 * time.Second
+)
+
+// lifecycle tracks in-flight event handlers and cleanup hooks so the bot can
+// shut down without interrupting work.
+type lifecycle struct {
+	mu       sync.RWMutex
+	closing  bool
+	inflight sync.WaitGroup
+	hooks    []func(ctx context.Context) error
+
+	// handlers are the registrations made with On for each event, so
+	// DispatchEvent can run them without a gateway connection.
+	handlers map[string][]*registration
+
+	// dispatches are the events DispatchEvent is running, and the work
+	// handlers spawned for them.
+	dispatchMu sync.Mutex
+	dispatches map[interface{}]*sync.WaitGroup
+}
+
+// registration is the inputs of a single call to On. Like disgord, only one
+// event is handled by a registration at a time.
+type registration struct {
+	mu     sync.Mutex
+	inputs []interface{}
+}
+
+// On registers event handlers like disgord.Client.On. Each handler is tracked
+// so shutdown can wait for it, and its event's Ctx is replaced with one
+// derived from the root context that times out after HandlerTimeout. Events
+// received after shutdown has started are dropped.
+//
+// Inputs may include Middlewares, which guard every handler after them.
+func (r *Rikka) On(event string, inputs ...interface{}) {
+	if counter := r.countEvents(event, inputs); counter != nil {
+		r.register(event, []interface{}{counter})
+	}
+
+	var (
+		wrapped []interface{}
+		guards  []Middleware
+	)
+	for _, e := range inputs {
+		switch mw := e.(type) {
+		case Middleware:
+			guards = append(guards, mw)
+		case func(*Event) bool:
+			guards = append(guards, mw)
+		default:
+			wrapped = append(wrapped, r.wrapHandler(event, e, guards))
+		}
+	}
+
+	r.register(event, wrapped)
+}
+
+// register adds a single registration of inputs for event, both to the gateway
+// client and for DispatchEvent.
+func (r *Rikka) register(event string, inputs []interface{}) {
+	r.lifecycle.mu.Lock()
+	if r.lifecycle.handlers == nil {
+		r.lifecycle.handlers = map[string][]*registration{}
+	}
+	r.lifecycle.handlers[event] = append(r.lifecycle.handlers[event], &registration{inputs: inputs})
+	r.lifecycle.mu.Unlock()
+
+	if r.Client != nil {
+		r.Client.On(event, inputs...)
+	}
+}
+
+// DispatchEvent runs the handlers registered with On for event as if evt was
+// received from the gateway, with s as their session. Registrations run in
+// order and each handles one event at a time, as they do in disgord. Unlike
+// the gateway, DispatchEvent waits for the handlers and any work they spawn,
+// such as commands, to return. It is used to drive the bot without connecting
+// to Discord, e.g. in tests.
+func (r *Rikka) DispatchEvent(s disgord.Session, event string, evt interface{}) {
+	r.lifecycle.mu.RLock()
+	registrations := r.lifecycle.handlers[event]
+	r.lifecycle.mu.RUnlock()
+
+	var spawned sync.WaitGroup
+	r.lifecycle.dispatchMu.Lock()
+	if r.lifecycle.dispatches == nil {
+		r.lifecycle.dispatches = map[interface{}]*sync.WaitGroup{}
+	}
+	r.lifecycle.dispatches[evt] = &spawned
+	r.lifecycle.dispatchMu.Unlock()
+
+	for _, e := range registrations {
+		e.mu.Lock()
+		dispatchRegistration(s, evt, e.inputs)
+		e.mu.Unlock()
+	}
+
+	r.lifecycle.dispatchMu.Lock()
+	delete(r.lifecycle.dispatches, evt)
+	r.lifecycle.dispatchMu.Unlock()
+	spawned.Wait()
+}
+
+// dispatchRegistration runs the inputs of a single call to On. Middlewares
+// run in order and stop the event if they return nil, then every handler
+// accepting the event's type is called.
+func dispatchRegistration(s disgord.Session, evt interface{}, inputs []interface{}) {
+	for _, e := range inputs {
+		if mw, ok := e.(disgord.Middleware); ok {
+			if evt = mw(evt); evt == nil {
+				return
+			}
+			continue
+		}
+
+		fn := reflect.ValueOf(e)
+		typ := fn.Type()
+		if typ.Kind() != reflect.Func || typ.NumIn() != 2 || !reflect.TypeOf(evt).AssignableTo(typ.In(1)) {
+			continue
+		}
+
+		sv := reflect.Zero(typ.In(0))
+		if s != nil {
+			sv = reflect.ValueOf(s)
+		}
+		fn.Call([]reflect.Value{sv, reflect.ValueOf(evt)})
+	}
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// wrapHandler wraps handlers of the form func(disgord.Session, *Event) and
+// recovers any panics they cause. Handlers are given a session that records
+// send failures, and only run if every guard passes. disgord middlewares and
+// handler controllers are returned as is.
+func (r *Rikka) wrapHandler(event string, h interface{}, guards []Middleware) interface{} {
+	fn := reflect.ValueOf(h)
+	typ := fn.Type()
+	if typ.Kind() != reflect.Func || typ.NumIn() != 2 || typ.NumOut() != 0 {
+		return h
+	}
+
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		if !r.acquire() {
+			return nil
+		}
+		defer r.lifecycle.inflight.Done()
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				s, _ := args[0].Interface().(disgord.Session)
+				r.reportPanic(event, s, args[1].Interface(), rec, debug.Stack())
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(r.ctx, r.HandlerTimeout)
+		defer cancel()
+		setEventContext(args[1], ctx)
+
+		if s, ok := args[0].Interface().(disgord.Session); ok && s != nil {
+			args[0] = reflect.ValueOf(instrumentedSession{s}).Convert(args[0].Type())
+		}
+
+		if len(guards) > 0 {
+			s, _ := args[0].Interface().(disgord.Session)
+			e := NewEvent(s, args[1].Interface())
+			for _, guard := range guards {
+				if !guard(e) {
+					return nil
+				}
+			}
+		}
+
+		return fn.Call(args)
+	}).Interface()
+}
+
+// spawn runs fn in its own goroutine with a new context that times out after
+// HandlerTimeout. It is tracked as in-flight so shutdown waits for it, and
+// panics are recovered and reported against evt. disgord runs the handlers of
+// a registration for one event at a time, so handlers that may take a while,
+// like commands, hand their work off to spawn rather than blocking other
+// events.
+func (r *Rikka) spawn(event string, s disgord.Session, evt interface{}, fn func(ctx context.Context)) {
+	if !r.acquire() {
+		return
+	}
+
+	r.lifecycle.dispatchMu.Lock()
+	dispatch := r.lifecycle.dispatches[evt]
+	if dispatch != nil {
+		dispatch.Add(1)
+	}
+	r.lifecycle.dispatchMu.Unlock()
+
+	go func() {
+		defer r.lifecycle.inflight.Done()
+		if dispatch != nil {
+			defer dispatch.Done()
+		}
+		defer func() {
+			if rec := recover(); rec != nil {
+				r.reportPanic(event, s, evt, rec, debug.Stack())
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(r.ctx, r.HandlerTimeout)
+		defer cancel()
+
+		fn(ctx)
+	}()
+}
+
+// setEventContext sets the Ctx field every disgord event has.
+func setEventContext(evt reflect.Value, ctx context.Context) {
+	if evt.Kind() != reflect.Ptr || evt.IsNil() || evt.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	field := evt.Elem().FieldByName("Ctx")
+	if field.IsValid() && field.CanSet() && field.Type() == contextType {
+		field.Set(reflect.ValueOf(ctx))
+	}
+}
+
+// acquire registers an in-flight handler. It returns false once shutdown has
+// started.
+func (r *Rikka) acquire() bool {
+	r.lifecycle.mu.RLock()
+	defer r.lifecycle.mu.RUnlock()
+
+	if r.lifecycle.closing {
+		return false
+	}
+
+	r.lifecycle.inflight.Add(1)
+	return true
+}
+
+// OnShutdown registers a hook that is run during shutdown after in-flight
+// handlers have finished, e.g. to flush pending writes. Hooks run in reverse
+// order of registration.
+func (r *Rikka) OnShutdown(fn func(ctx context.Context) error) {
+	r.lifecycle.mu.Lock()
+	r.lifecycle.hooks = append(r.lifecycle.hooks, fn)
+	r.lifecycle.mu.Unlock()
+}
+
+// Open connects to the gateway and blocks until the process receives SIGINT
+// or SIGTERM, then shuts down gracefully.
+func (r *Rikka) Open() error {
+	r.On("READY", func(s disgord.Session, h *disgord.Ready) {
+		r.Log.Info(h.Ctx, "ready")
+		r.setReady(true)
+	})
+	r.On("RESUMED", func(s disgord.Session, h *disgord.Resumed) {
+		r.Log.Info(h.Ctx, "resumed")
+		r.setReady(true)
+	})
+
+	err := r.serveHTTP()
+	if err != nil {
+		return err
+	}
+
+	self, err := r.Client.Myself(r.ctx)
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to get self", slog.Error(err))
+	} else {
+		r.self = self
+	}
+
+	err = r.Client.Connect(r.ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to connect to gateway: %w", err)
+	}
+
+	err = r.Scheduler.EnsureRecurring(auditPurgeJob, auditPurgeSchedule, nil)
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to schedule audit log purge", slog.Error(err))
+	}
+	go r.Scheduler.Run(r.ctx, r.Client)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case s := <-sig:
+		r.Log.Info(r.ctx, "received signal, shutting down", slog.F("signal", s.String()))
+	case <-r.ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.ShutdownTimeout)
+	defer cancel()
+
+	return r.Shutdown(ctx)
+}
+
+// Shutdown stops accepting events, waits for in-flight handlers until ctx is
+// done, runs shutdown hooks and closes the gateway connection. Handlers still
+// running when ctx is done have their contexts cancelled. Hooks are given up
+// to ShutdownTimeout regardless of how long draining took.
+func (r *Rikka) Shutdown(ctx context.Context) error {
+	r.lifecycle.mu.Lock()
+	r.lifecycle.closing = true
+	hooks := r.lifecycle.hooks
+	r.lifecycle.mu.Unlock()
+	r.setReady(false)
+
+	drained := make(chan struct{})
+	go func() {
+		r.lifecycle.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		r.Log.Info(ctx, "drained in-flight handlers")
+	case <-ctx.Done():
+		r.Log.Warn(ctx, "timed out waiting for in-flight handlers, cancelling them")
+	}
+	r.cancel()
+
+	// Draining may have used up ctx, so hooks get a deadline of their own.
+	hookCtx, cancel := context.WithTimeout(context.Background(), r.ShutdownTimeout)
+	defer cancel()
+
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		err := hooks[i](hookCtx)
+		if err != nil {
+			r.Log.Error(ctx, "shutdown hook failed", slog.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if r.Client != nil {
+		err := r.Client.Disconnect()
+		if err != nil && firstErr == nil {
+			firstErr = xerrors.Errorf("failed to disconnect from gateway: %w", err)
+		}
+	}
+
+	return firstErr
+}
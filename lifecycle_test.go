@@ -0,0 +1,97 @@
+package rikka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestWrapHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &Rikka{ctx: ctx, cancel: cancel, HandlerTimeout: time.Minute}
+
+	var (
+		calls  int
+		gotCtx context.Context
+	)
+	h := r.wrapHandler("MESSAGE_CREATE", func(s disgord.Session, mc *disgord.MessageCreate) {
+		calls++
+		gotCtx = mc.Ctx
+	}, nil).(func(disgord.Session, *disgord.MessageCreate))
+
+	h(nil, &disgord.MessageCreate{Ctx: context.Background()})
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if _, ok := gotCtx.Deadline(); !ok {
+		t.Error("handler context has no deadline")
+	}
+	if gotCtx.Err() == nil {
+		t.Error("handler context not cancelled after the handler returned")
+	}
+
+	r.lifecycle.closing = true
+	h(nil, &disgord.MessageCreate{Ctx: context.Background()})
+	if calls != 1 {
+		t.Error("handler called after shutdown started")
+	}
+
+	mw := func(i interface{}) interface{} { return i }
+	if _, ok := r.wrapHandler("MESSAGE_CREATE", mw, nil).(disgord.Middleware); !ok {
+		t.Error("middleware was wrapped")
+	}
+}
+
+func TestWrapHandlerRecover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &Rikka{Log: slog.Make(), ctx: ctx, cancel: cancel, HandlerTimeout: time.Minute}
+
+	h := r.wrapHandler("MESSAGE_CREATE", func(s disgord.Session, mc *disgord.MessageCreate) {
+		_ = mc.Message.Author.ID
+	}, nil).(func(disgord.Session, *disgord.MessageCreate))
+
+	h(nil, &disgord.MessageCreate{Message: &disgord.Message{}})
+}
+
+func TestShutdownHookDeadline(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+	r.Log = slog.Make()
+	// Not connected, so there is nothing to disconnect.
+	r.Client = nil
+
+	// A handler that outlives the drain timeout.
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	r.On("MESSAGE_CREATE", func(s disgord.Session, mc *disgord.MessageCreate) {
+		close(started)
+		<-release
+	})
+	go r.DispatchEvent(nil, "MESSAGE_CREATE", &disgord.MessageCreate{Message: &disgord.Message{}})
+	<-started
+
+	var hookErr error
+	r.OnShutdown(func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = r.Shutdown(ctx)
+
+	if hookErr != nil {
+		t.Errorf("hook got a done context: %v", hookErr)
+	}
+}
@@ -0,0 +1,163 @@
+package rikka
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "gateway_events_total",
+		Help:      "Gateway events received, by event type.",
+	}, []string{"event"})
+
+	commandsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "commands_total",
+		Help:      "Command invocations, by command.",
+	}, []string{"command"})
+	commandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rikka",
+		Name:      "command_duration_seconds",
+		Help:      "Time taken to handle a command, by command.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"command"})
+	commandErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "command_errors_total",
+		Help:      "Errors reported while handling a command, by command and kind.",
+	}, []string{"command", "kind"})
+
+	fdbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rikka",
+		Name:      "fdb_transaction_duration_seconds",
+		Help:      "Time taken to commit an FDB transaction including retries, by type.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+	}, []string{"type"})
+	fdbRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "fdb_transaction_retries_total",
+		Help:      "FDB transaction attempts that were retried, by type.",
+	}, []string{"type"})
+
+	jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "jobs_total",
+		Help:      "Scheduled jobs run, by handler and outcome.",
+	}, []string{"handler", "outcome"})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rikka",
+		Name:      "job_duration_seconds",
+		Help:      "Time taken to run a scheduled job, by handler.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"handler"})
+
+	sendFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "rikka",
+		Name:      "message_send_failures_total",
+		Help:      "Messages that failed to send.",
+	})
+)
+
+// countEvents returns a handler counting events of the given type, taking
+// the same event as the handlers in inputs. It is registered on its own the
+// first time a handler is added for each event, so every event is counted
+// once no matter how many handlers it has or which of them drop it. It
+// returns nil if the event is already counted or inputs has no handlers.
+func (r *Rikka) countEvents(event string, inputs []interface{}) interface{} {
+	var evtType reflect.Type
+	for _, e := range inputs {
+		typ := reflect.TypeOf(e)
+		if typ != nil && typ.Kind() == reflect.Func && typ.NumIn() == 2 && typ.NumOut() == 0 && typ.In(0) == sessionType {
+			evtType = typ
+			break
+		}
+	}
+	if evtType == nil {
+		return nil
+	}
+
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+
+	if r.countedEvents == nil {
+		r.countedEvents = map[string]bool{}
+	}
+	if r.countedEvents[event] {
+		return nil
+	}
+	r.countedEvents[event] = true
+
+	counter := eventsTotal.WithLabelValues(event)
+	return reflect.MakeFunc(evtType, func([]reflect.Value) []reflect.Value {
+		counter.Inc()
+		return nil
+	}).Interface()
+}
+
+type commandKey struct{}
+
+// withCommand marks ctx as belonging to an invocation of command, so errors
+// reported with it are attributed to the command.
+func withCommand(ctx context.Context, command string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, commandKey{}, command)
+}
+
+// commandFromContext returns the command ctx belongs to, if any.
+func commandFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	command, ok := ctx.Value(commandKey{}).(string)
+	return command, ok
+}
+
+func countCommandError(ctx context.Context, kind string) {
+	if command, ok := commandFromContext(ctx); ok {
+		commandErrors.WithLabelValues(command, kind).Inc()
+	}
+}
+
+// observeCommand records an invocation of command that started at start.
+func observeCommand(command string, start time.Time) {
+	commandsTotal.WithLabelValues(command).Inc()
+	commandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+}
+
+// observeJob records a run of a job with handler that started at start.
+func observeJob(handler, outcome string, start time.Time) {
+	jobsTotal.WithLabelValues(handler, outcome).Inc()
+	jobDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+}
+
+// observeTransaction records a transaction of typ that started at start and
+// took attempts tries to commit.
+func observeTransaction(typ string, start time.Time, attempts int) {
+	fdbDuration.WithLabelValues(typ).Observe(time.Since(start).Seconds())
+	if attempts > 1 {
+		fdbRetries.WithLabelValues(typ).Add(float64(attempts - 1))
+	}
+}
+
+// instrumentedSession counts failed sends on the session handlers are given.
+type instrumentedSession struct {
+	disgord.Session
+}
+
+func (s instrumentedSession) SendMsg(ctx context.Context, channelID disgord.Snowflake, data ...interface{}) (*disgord.Message, error) {
+	msg, err := s.Session.SendMsg(ctx, channelID, data...)
+	if err != nil {
+		sendFailures.Inc()
+	}
+
+	return msg, err
+}
@@ -0,0 +1,66 @@
+package rikka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestCountEvents(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+
+	var ran int
+	handler := func(s disgord.Session, evt *disgord.TypingStart) { ran++ }
+	drop := Middleware(func(*Event) bool { return false })
+	r.On(disgord.EvtTypingStart, drop, handler)
+	r.On(disgord.EvtTypingStart, handler)
+
+	before := testutil.ToFloat64(eventsTotal.WithLabelValues(disgord.EvtTypingStart))
+	r.DispatchEvent(nil, disgord.EvtTypingStart, &disgord.TypingStart{})
+	r.DispatchEvent(nil, disgord.EvtTypingStart, &disgord.TypingStart{})
+	if got := testutil.ToFloat64(eventsTotal.WithLabelValues(disgord.EvtTypingStart)) - before; got != 2 {
+		t.Fatalf("counted %v events, want 2", got)
+	}
+	if ran != 2 {
+		t.Fatalf("handlers ran %d times, want 2", ran)
+	}
+}
+
+type failingSession struct {
+	disgord.Session
+}
+
+func (failingSession) SendMsg(context.Context, disgord.Snowflake, ...interface{}) (*disgord.Message, error) {
+	return nil, errors.New("failed")
+}
+
+func TestInstrumentedSession(t *testing.T) {
+	before := testutil.ToFloat64(sendFailures)
+
+	_, err := instrumentedSession{failingSession{}}.SendMsg(context.Background(), 1, "hi")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got := testutil.ToFloat64(sendFailures) - before; got != 1 {
+		t.Fatalf("counted %v failures, want 1", got)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	before := testutil.ToFloat64(commandErrors.WithLabelValues("test", "not_found"))
+
+	ctx := withCommand(context.Background(), "test")
+	countCommandError(ctx, ErrorKindNotFound.String())
+	countCommandError(context.Background(), "not_found")
+
+	if got := testutil.ToFloat64(commandErrors.WithLabelValues("test", "not_found")) - before; got != 1 {
+		t.Fatalf("counted %v errors, want 1", got)
+	}
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"sync"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+// Blocker decides whether events from a user or guild are ignored.
+type Blocker interface {
+	Blocked(guildID, userID disgord.Snowflake) bool
+}
+
+// Blocklist is an in-memory Blocker.
+type Blocklist struct {
+	mu     sync.RWMutex
+	users  map[disgord.Snowflake]struct{}
+	guilds map[disgord.Snowflake]struct{}
+}
+
+// NewBlocklist returns an empty Blocklist.
+func NewBlocklist() *Blocklist {
+	return &Blocklist{
+		users:  map[disgord.Snowflake]struct{}{},
+		guilds: map[disgord.Snowflake]struct{}{},
+	}
+}
+
+func (b *Blocklist) BlockUser(id disgord.Snowflake) {
+	b.mu.Lock()
+	b.users[id] = struct{}{}
+	b.mu.Unlock()
+}
+
+func (b *Blocklist) UnblockUser(id disgord.Snowflake) {
+	b.mu.Lock()
+	delete(b.users, id)
+	b.mu.Unlock()
+}
+
+func (b *Blocklist) BlockGuild(id disgord.Snowflake) {
+	b.mu.Lock()
+	b.guilds[id] = struct{}{}
+	b.mu.Unlock()
+}
+
+func (b *Blocklist) UnblockGuild(id disgord.Snowflake) {
+	b.mu.Lock()
+	delete(b.guilds, id)
+	b.mu.Unlock()
+}
+
+func (b *Blocklist) Blocked(guildID, userID disgord.Snowflake) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, user := b.users[userID]
+	_, guild := b.guilds[guildID]
+	return user || guild
+}
+
+// IgnoreBlocked drops events caused by blocked users or happening in blocked
+// guilds.
+func IgnoreBlocked(b Blocker) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		guildID, _ := e.Guild()
+		return !b.Blocked(guildID, e.UserID)
+	}
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+)
+
+// GuildOnly drops events that did not happen in a guild.
+func GuildOnly(e *rikka.Event) bool {
+	guildID, err := e.Guild()
+	return err == nil && !guildID.IsZero()
+}
+
+// DMOnly drops events that did not happen in a direct message.
+func DMOnly(e *rikka.Event) bool {
+	if e.ChannelID.IsZero() {
+		return false
+	}
+
+	guildID, err := e.Guild()
+	return err == nil && guildID.IsZero()
+}
+
+// RequirePermissions drops events caused by users without all of perms in the
+// guild the event happened in.
+func RequirePermissions(r *rikka.Rikka, perms disgord.PermissionBits) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		guildID, err := e.Guild()
+		if err != nil || guildID.IsZero() || e.UserID.IsZero() || e.Session == nil {
+			return false
+		}
+
+		has, err := r.MemberPermissions(e.Ctx, e.Session, guildID, e.UserID)
+		if err != nil {
+			r.Log.Error(e.Ctx, "failed to get member permissions", slog.Error(err))
+			return false
+		}
+
+		return has&perms == perms
+	}
+}
+
+// RequireRole drops events caused by users who have none of roles in the
+// guild the event happened in.
+func RequireRole(r *rikka.Rikka, roles ...disgord.Snowflake) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		guildID, err := e.Guild()
+		if err != nil || guildID.IsZero() || e.UserID.IsZero() {
+			return false
+		}
+
+		member := e.Member
+		if member == nil || member.Roles == nil {
+			if e.Session == nil {
+				return false
+			}
+
+			member, err = e.Session.GetMember(e.Ctx, guildID, e.UserID)
+			if err != nil {
+				r.Log.Error(e.Ctx, "failed to get member", slog.Error(err))
+				return false
+			}
+		}
+
+		for _, has := range member.Roles {
+			for _, want := range roles {
+				if has == want {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// Cooldown drops events once a bucket has used up any of cooldowns. name
+// keeps the buckets separate from those of other cooldowns.
+func Cooldown(r *rikka.Rikka, name string, cooldowns ...rikka.Cooldown) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		ok, _ := r.TakeCooldown(name, e, cooldowns...)
+		return ok
+	}
+}
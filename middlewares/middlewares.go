@@ -0,0 +1,40 @@
+// Package middlewares provides rikka.Middlewares for filtering events, and
+// helpers for combining them. They work on every gateway event type, though
+// an event that lacks the information a middleware needs is dropped by it,
+// e.g. GuildOnly drops READY.
+package middlewares
+
+import (
+	rikka "github.com/coadler/rikka2"
+)
+
+// All passes events that pass every middleware, checked in order.
+func All(mws ...rikka.Middleware) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		for _, mw := range mws {
+			if !mw(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any passes events that pass at least one middleware, checked in order.
+func Any(mws ...rikka.Middleware) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		for _, mw := range mws {
+			if mw(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not passes events mw drops.
+func Not(mw rikka.Middleware) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		return !mw(e)
+	}
+}
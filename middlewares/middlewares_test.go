@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestGuards(t *testing.T) {
+	h := rikkatest.New(t)
+	dm := &disgord.Channel{ID: 99, Type: disgord.ChannelTypeDM}
+	h.Session.AddChannel(dm)
+
+	role := &disgord.Role{ID: 50, Name: "mods", Permissions: disgord.PermissionManageMessages}
+	h.Guild.Roles = append(h.Guild.Roles, role)
+	mod := &disgord.User{ID: 4, Username: "mod"}
+	h.Session.AddMember(h.Guild.ID, &disgord.Member{User: mod, Roles: []disgord.Snowflake{role.ID}})
+
+	reaction := func(channel, user disgord.Snowflake) *rikka.Event {
+		return rikka.NewEvent(h.Session, &disgord.MessageReactionAdd{Ctx: context.Background(), ChannelID: channel, UserID: user})
+	}
+
+	tests := []struct {
+		name string
+		mw   rikka.Middleware
+		e    *rikka.Event
+		want bool
+	}{
+		{"guild only in guild", GuildOnly, reaction(h.Channel.ID, h.User.ID), true},
+		{"guild only in dm", GuildOnly, reaction(dm.ID, h.User.ID), false},
+		{"dm only in dm", DMOnly, reaction(dm.ID, h.User.ID), true},
+		{"dm only without channel", DMOnly, rikka.NewEvent(h.Session, &disgord.Ready{}), false},
+		{"permissions missing", RequirePermissions(h.Rikka, disgord.PermissionManageMessages), reaction(h.Channel.ID, h.User.ID), false},
+		{"permissions from role", RequirePermissions(h.Rikka, disgord.PermissionManageMessages), reaction(h.Channel.ID, mod.ID), true},
+		{"permissions of owner", RequirePermissions(h.Rikka, disgord.PermissionBanMembers), reaction(h.Channel.ID, h.Owner.ID), true},
+		{"role missing", RequireRole(h.Rikka, role.ID), reaction(h.Channel.ID, h.User.ID), false},
+		{"role present", RequireRole(h.Rikka, role.ID), reaction(h.Channel.ID, mod.ID), true},
+		{"server owner", ServerOwnerOnly(h.Rikka), reaction(h.Channel.ID, h.Owner.ID), true},
+		{"not server owner", ServerOwnerOnly(h.Rikka), reaction(h.Channel.ID, h.User.ID), false},
+		{"all", All(GuildOnly, RequireRole(h.Rikka, role.ID)), reaction(h.Channel.ID, h.User.ID), false},
+		{"any", Any(DMOnly, RequireRole(h.Rikka, role.ID)), reaction(h.Channel.ID, mod.ID), true},
+		{"not", Not(GuildOnly), reaction(h.Channel.ID, mod.ID), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mw(tt.e); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreBlocked(t *testing.T) {
+	h := rikkatest.New(t)
+
+	var calls int
+	blocked := NewBlocklist()
+	h.Rikka.On(disgord.EvtMessageCreate, NoBots, IgnoreBlocked(blocked), func(s disgord.Session, mc *disgord.MessageCreate) {
+		calls++
+	})
+
+	h.Send("hi")
+	blocked.BlockUser(h.User.ID)
+	h.Send("hi")
+	h.SendAs(&disgord.User{ID: 5, Bot: true}, "hi")
+	blocked.UnblockUser(h.User.ID)
+	blocked.BlockGuild(h.Guild.ID)
+	h.SendAs(h.Owner, "hi")
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	h := rikkatest.New(t)
+	mw := Cooldown(h.Rikka, "test", rikka.Cooldown{Bucket: rikka.CooldownUser, Burst: 1, Per: time.Hour})
+
+	e := rikka.NewEvent(h.Session, &disgord.MessageCreate{Message: h.NewMessage("")})
+	if !mw(e) {
+		t.Fatal("first event dropped")
+	}
+	if mw(e) {
+		t.Fatal("second event passed")
+	}
+}
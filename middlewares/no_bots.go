@@ -1,36 +1,15 @@
 package middlewares
 
 import (
-	"fmt"
-
-	"github.com/andersfylling/disgord"
+	rikka "github.com/coadler/rikka2"
 )
 
-func NoBots(i interface{}) interface{} {
-	switch mc := i.(type) {
-	case *disgord.MessageCreate:
-		if messageIsBot(mc.Message) {
-			return nil
-		}
-
-	case *disgord.MessageUpdate:
-		if messageIsBot(mc.Message) {
-			return nil
-		}
-
-	case *disgord.MessageDelete:
-	default:
-		fmt.Printf("unknown: %T\n", i)
-	}
-
-	return i
-}
-
-func messageIsBot(msg *disgord.Message) bool {
-	if msg.Author != nil {
-		return msg.Author.Bot
+// NoBots drops events caused by bots.
+func NoBots(e *rikka.Event) bool {
+	if e.Author != nil {
+		return !e.Author.Bot
 	}
 
-	// TODO: messages with a nil author are embeds sent by bots.
-	return true
+	// messages with a nil author are embeds sent by bots.
+	return e.Message == nil
 }
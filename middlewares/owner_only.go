@@ -1,67 +1,37 @@
 package middlewares
 
 import (
-	"context"
-	"fmt"
-
-	"github.com/andersfylling/disgord"
-
 	rikka "github.com/coadler/rikka2"
 )
 
-func BotOwnerOnly(i interface{}) interface{} {
-	switch mc := i.(type) {
-	case *disgord.MessageCreate:
-		if !messageIsBotOwner(mc.Message) {
-			return nil
-		}
-
-	case *disgord.MessageUpdate:
-		if !messageIsBotOwner(mc.Message) {
-			return nil
-		}
-
-	default:
-		fmt.Printf("unknown: %T", i)
-	}
-
-	return i
-}
-
-func messageIsBotOwner(m *disgord.Message) bool {
-	if m.Author != nil {
-		return m.Author.ID == disgord.Snowflake(105484726235607040)
+// BotOwnerOnly drops events not caused by a bot owner.
+func BotOwnerOnly(r *rikka.Rikka) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		return !e.UserID.IsZero() && r.IsOwner(e.UserID)
 	}
-
-	return false
 }
 
-func ServerOwnerOnly(r *rikka.Rikka) func(i interface{}) interface{} {
-	return func(i interface{}) interface{} {
-		switch mc := i.(type) {
-		case *disgord.MessageCreate:
-			if !messageIsServerOwner(r, mc.Message) {
-				return nil
-			}
-
-		case *disgord.MessageUpdate:
-			if !messageIsServerOwner(r, mc.Message) {
-				return nil
-			}
+// ServerOwnerOnly drops events not caused by the owner of the guild they
+// happened in. Bot owners are let through everywhere.
+func ServerOwnerOnly(r *rikka.Rikka) rikka.Middleware {
+	return func(e *rikka.Event) bool {
+		if e.UserID.IsZero() {
+			return false
+		}
+		if r.IsOwner(e.UserID) {
+			return true
+		}
 
-		default:
-			fmt.Printf("unknown: %T", i)
+		guildID, err := e.Guild()
+		if err != nil || guildID.IsZero() || e.Session == nil {
+			return false
 		}
 
-		return i
-	}
-}
+		g, err := e.Session.GetGuild(e.Ctx, guildID)
+		if err != nil {
+			return false
+		}
 
-func messageIsServerOwner(r *rikka.Rikka, m *disgord.Message) bool {
-	g, err := r.Client.GetGuild(context.Background(), m.GuildID)
-	if err != nil {
-		return false
+		return g.OwnerID == e.UserID
 	}
-
-	return g.OwnerID == m.Author.ID || messageIsBotOwner(m)
 }
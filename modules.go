@@ -0,0 +1,251 @@
+package rikka
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+// ModuleCore is the module of commands that don't declare one. It holds the
+// commands needed to configure the bot, so it can't be disabled.
+const ModuleCore = "core"
+
+// CommandModuler is implemented by commands that belong to a module. Guild
+// admins can disable a module, which skips both its commands and the event
+// listeners it registers in that guild.
+type CommandModuler interface {
+	Module() string
+}
+
+// commandModule returns the lowercased module of cmd.
+func commandModule(cmd Command) string {
+	if m, ok := cmd.(CommandModuler); ok {
+		if name := strings.ToLower(m.Module()); name != "" {
+			return name
+		}
+	}
+
+	return ModuleCore
+}
+
+// modules stores the modules disabled in each guild. Modules are enabled
+// unless they are disabled.
+type modules struct {
+	dir storage.Subspace
+
+	mu    sync.RWMutex
+	cache map[disgord.Snowflake]map[string]bool
+	// gens is incremented every time a guild's modules change, so a fill
+	// that read them before the change isn't cached.
+	gens map[disgord.Snowflake]uint64
+}
+
+// Modules returns the names of every module with a registered command,
+// sorted.
+func (r *Rikka) Modules() []string {
+	cmds, _ := r.registered()
+	seen := map[string]bool{}
+	for _, e := range cmds {
+		seen[commandModule(e)] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ModuleCommands returns the names of the commands in a module.
+func (r *Rikka) ModuleCommands(module string) []string {
+	cmds, _ := r.registered()
+	var names []string
+	for _, e := range cmds {
+		if commandModule(e) != strings.ToLower(module) {
+			continue
+		}
+		for _, h := range e.Help() {
+			names = append(names, h.Name)
+		}
+	}
+
+	return names
+}
+
+// DisabledModules returns the set of modules disabled in a guild.
+func (r *Rikka) DisabledModules(guildID disgord.Snowflake) (map[string]bool, error) {
+	disabled, err := r.disabledModules(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := make(map[string]bool, len(disabled))
+	for k, v := range disabled {
+		cp[k] = v
+	}
+
+	return cp, nil
+}
+
+// disabledModules returns the cached set of modules disabled in a guild. It
+// must not be modified.
+func (r *Rikka) disabledModules(guildID disgord.Snowflake) (map[string]bool, error) {
+	r.modules.mu.RLock()
+	cached, ok := r.modules.cache[guildID]
+	gen := r.modules.gens[guildID]
+	r.modules.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	var disabled map[string]bool
+	err := r.ReadTransact(func(t storage.ReadTransaction) error {
+		disabled = map[string]bool{}
+		kvs, err := t.Snapshot().GetRange(r.modules.dir.Sub(uint64(guildID)).Range(), storage.RangeOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range kvs {
+			tup, err := r.modules.dir.Unpack(kv.Key)
+			if err != nil {
+				return xerrors.Errorf("failed to unpack module key: %w", err)
+			}
+
+			name, _ := tup[1].(string)
+			disabled[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to transact disabled modules: %w", err)
+	}
+
+	r.cacheModules(guildID, gen, disabled)
+	return disabled, nil
+}
+
+// cacheModules caches the modules disabled in a guild, unless they changed
+// since gen was read.
+func (r *Rikka) cacheModules(guildID disgord.Snowflake, gen uint64, disabled map[string]bool) {
+	r.modules.mu.Lock()
+	defer r.modules.mu.Unlock()
+
+	if r.modules.gens[guildID] != gen {
+		return
+	}
+	r.modules.cache[guildID] = disabled
+}
+
+// ModuleEnabled returns true if module is enabled in a guild. Modules are
+// always enabled in private messages, and are treated as enabled if the
+// guild's modules fail to load.
+func (r *Rikka) ModuleEnabled(guildID disgord.Snowflake, module string) bool {
+	if guildID.IsZero() || module == ModuleCore {
+		return true
+	}
+
+	disabled, err := r.disabledModules(guildID)
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to load disabled modules", slog.Error(err), slog.F("guild_id", guildID))
+		return true
+	}
+
+	return !disabled[module]
+}
+
+// EnableModule enables a module in a guild.
+func (r *Rikka) EnableModule(guildID disgord.Snowflake, module string) error {
+	module, err := r.checkModule(module)
+	if err != nil {
+		return err
+	}
+
+	err = r.Transact(func(t storage.Transaction) error {
+		t.Clear(r.fmtModuleKey(guildID, module))
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact module enable: %w", err)
+	}
+
+	r.invalidateModules(guildID)
+	return nil
+}
+
+// DisableModule disables a module in a guild. The core module can't be
+// disabled.
+func (r *Rikka) DisableModule(guildID disgord.Snowflake, module string) error {
+	module, err := r.checkModule(module)
+	if err != nil {
+		return err
+	}
+	if module == ModuleCore {
+		return BadInput("The `%s` module can't be disabled", ModuleCore)
+	}
+
+	err = r.Transact(func(t storage.Transaction) error {
+		t.Set(r.fmtModuleKey(guildID, module), []byte{})
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact module disable: %w", err)
+	}
+
+	r.invalidateModules(guildID)
+	return nil
+}
+
+// checkModule returns the lowercased name of module if it exists.
+func (r *Rikka) checkModule(module string) (string, error) {
+	module = strings.ToLower(module)
+	for _, e := range r.Modules() {
+		if e == module {
+			return module, nil
+		}
+	}
+
+	return "", BadInput("No module named `%s`", module)
+}
+
+// moduleOn returns the function passed to a command's Register. Listeners of
+// commands outside the core module are guarded so they don't run in guilds
+// that disabled the module.
+func (r *Rikka) moduleOn(module string) func(event string, inputs ...interface{}) {
+	if module == ModuleCore {
+		return r.On
+	}
+
+	guard := Middleware(func(e *Event) bool {
+		guildID, err := e.Guild()
+		if err != nil {
+			return true
+		}
+
+		return r.ModuleEnabled(guildID, module)
+	})
+
+	return func(event string, inputs ...interface{}) {
+		r.On(event, append([]interface{}{guard}, inputs...)...)
+	}
+}
+
+func (r *Rikka) invalidateModules(guildID disgord.Snowflake) {
+	r.modules.mu.Lock()
+	delete(r.modules.cache, guildID)
+	r.modules.gens[guildID]++
+	r.modules.mu.Unlock()
+}
+
+func (r *Rikka) fmtModuleKey(guildID disgord.Snowflake, module string) storage.Key {
+	return r.modules.dir.Pack(tuple.Tuple{uint64(guildID), module})
+}
@@ -0,0 +1,49 @@
+package rikka
+
+import (
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+type moduleCmd struct {
+	testCmd
+	module string
+}
+
+func (c *moduleCmd) Module() string { return c.module }
+
+func TestDisabledModulesCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+	r.RegisterCommands(&moduleCmd{testCmd: testCmd{help: CommandHelp{Name: "seen"}}, module: "info"})
+
+	const guild disgord.Snowflake = 10
+
+	// Changes to the returned set don't leak into the cache.
+	disabled, err := r.DisabledModules(guild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled["info"] = true
+	if !r.ModuleEnabled(guild, "info") {
+		t.Fatal("modifying the returned set disabled a module")
+	}
+
+	// A fill that read the modules before they changed isn't cached.
+	r.invalidateModules(guild)
+	r.modules.mu.RLock()
+	gen := r.modules.gens[guild]
+	r.modules.mu.RUnlock()
+
+	if err := r.DisableModule(guild, "info"); err != nil {
+		t.Fatal(err)
+	}
+	r.cacheModules(guild, gen, map[string]bool{})
+	if r.ModuleEnabled(guild, "info") {
+		t.Fatal("stale fill was cached after the module was disabled")
+	}
+}
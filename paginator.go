@@ -0,0 +1,229 @@
+package rikka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// DefaultPaginatorTimeout is how long a paginated message responds to
+// reactions after it was last used.
+const DefaultPaginatorTimeout = 2 * time.Minute
+
+// Reactions used to navigate paginated messages.
+const (
+	PagePrevious = "◀"
+	PageNext     = "▶"
+	PageStop     = "⏹"
+)
+
+// PageSource provides the pages of a paginated message. Pages are only
+// requested when they are shown, so they may be loaded lazily.
+type PageSource interface {
+	// Len returns the number of pages.
+	Len() int
+	// Page returns page i, starting at 0.
+	Page(ctx context.Context, i int) (*disgord.Embed, error)
+}
+
+// EmbedPages is a PageSource of pages that are already built.
+type EmbedPages []*disgord.Embed
+
+func (p EmbedPages) Len() int { return len(p) }
+
+func (p EmbedPages) Page(_ context.Context, i int) (*disgord.Embed, error) { return p[i], nil }
+
+// PageFunc is a PageSource that calls fn to build each of n pages.
+func PageFunc(n int, fn func(ctx context.Context, i int) (*disgord.Embed, error)) PageSource {
+	return pageFunc{n: n, fn: fn}
+}
+
+type pageFunc struct {
+	n  int
+	fn func(ctx context.Context, i int) (*disgord.Embed, error)
+}
+
+func (p pageFunc) Len() int { return p.n }
+
+func (p pageFunc) Page(ctx context.Context, i int) (*disgord.Embed, error) { return p.fn(ctx, i) }
+
+// paginators tracks the paginated messages that still respond to reactions.
+type paginators struct {
+	mu     sync.Mutex
+	active map[disgord.Snowflake]*pagination
+}
+
+// pagination is a paginated message.
+type pagination struct {
+	r       *Rikka
+	s       disgord.Session
+	src     PageSource
+	msg     *disgord.Message
+	invoker disgord.Snowflake
+
+	mu    sync.Mutex
+	page  int
+	timer *time.Timer
+}
+
+// Paginate replies to msg with the first page of src and adds reactions to
+// navigate between pages. Only the author of msg can change the page, which
+// edits the reply in place. The reactions are removed once nobody has used
+// them for PaginatorTimeout, or when the author stops the pagination.
+func (r *Rikka) Paginate(ctx context.Context, s disgord.Session, msg *disgord.Message, src PageSource) error {
+	if src.Len() == 0 {
+		return xerrors.New("no pages to paginate")
+	}
+
+	first, err := renderPage(ctx, src, 0)
+	if err != nil {
+		return err
+	}
+
+	reply, err := s.SendMsg(ctx, msg.ChannelID, disgord.CreateMessageParams{Embed: first})
+	if err != nil {
+		return xerrors.Errorf("failed to send first page: %w", err)
+	}
+	if src.Len() == 1 {
+		return nil
+	}
+
+	p := &pagination{
+		r:       r,
+		s:       s,
+		src:     src,
+		msg:     reply,
+		invoker: msg.Author.ID,
+	}
+
+	r.paginators.mu.Lock()
+	if r.paginators.active == nil {
+		r.paginators.active = map[disgord.Snowflake]*pagination{}
+	}
+	// A command re-run after an edit paginates the message it sent before.
+	if old, ok := r.paginators.active[reply.ID]; ok {
+		old.timer.Stop()
+	}
+	r.paginators.active[reply.ID] = p
+	// The timer starts once p is active so stop can't run before it, and
+	// under the lock so reactions see it.
+	p.timer = time.AfterFunc(r.PaginatorTimeout, p.stop)
+	r.paginators.mu.Unlock()
+
+	for _, e := range []string{PagePrevious, PageNext, PageStop} {
+		err := s.CreateReaction(ctx, reply.ChannelID, reply.ID, e)
+		if err != nil {
+			r.Log.Warn(ctx, "failed to add pagination reaction", slog.Error(err), slog.F("channel_id", reply.ChannelID))
+			break
+		}
+	}
+
+	return nil
+}
+
+// handlePageReaction turns the page of a paginated message when its invoker
+// reacts to it.
+func (r *Rikka) handlePageReaction(s disgord.Session, h *disgord.MessageReactionAdd) {
+	if r.self != nil && h.UserID == r.self.ID {
+		return
+	}
+
+	r.paginators.mu.Lock()
+	p, ok := r.paginators.active[h.MessageID]
+	r.paginators.mu.Unlock()
+	if !ok || h.PartialEmoji == nil {
+		return
+	}
+
+	// Remove the reaction so it can be used again. This needs the Manage
+	// Messages permission, so failures are ignored.
+	_ = s.DeleteUserReaction(h.Ctx, h.ChannelID, h.MessageID, h.UserID, h.PartialEmoji.Name)
+	if h.UserID != p.invoker {
+		return
+	}
+
+	switch h.PartialEmoji.Name {
+	case PagePrevious:
+		p.turn(h.Ctx, s, -1)
+	case PageNext:
+		p.turn(h.Ctx, s, 1)
+	case PageStop:
+		p.stop()
+	}
+}
+
+// turn moves delta pages, wrapping around at either end.
+func (p *pagination) turn(ctx context.Context, s disgord.Session, delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := p.src.Len()
+	page := ((p.page+delta)%n + n) % n
+	p.timer.Reset(p.r.PaginatorTimeout)
+
+	embed, err := renderPage(ctx, p.src, page)
+	if err != nil {
+		p.r.Log.Error(ctx, "failed to load page", slog.Error(err), slog.F("page", page))
+		return
+	}
+
+	_, err = s.SetMsgEmbed(ctx, p.msg.ChannelID, p.msg.ID, embed)
+	if err != nil {
+		p.r.Log.Error(ctx, "failed to edit paginated message", slog.Error(err), slog.F("channel_id", p.msg.ChannelID))
+		return
+	}
+	p.page = page
+}
+
+// stop stops responding to reactions and removes them from the message.
+func (p *pagination) stop() {
+	p.r.paginators.mu.Lock()
+	active := p.r.paginators.active[p.msg.ID] == p
+	if active {
+		delete(p.r.paginators.active, p.msg.ID)
+	}
+	p.r.paginators.mu.Unlock()
+	if !active {
+		return
+	}
+
+	p.mu.Lock()
+	p.timer.Stop()
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(p.r.ctx, p.r.HandlerTimeout)
+	defer cancel()
+
+	err := p.s.DeleteAllReactions(ctx, p.msg.ChannelID, p.msg.ID)
+	if err != nil {
+		p.r.Log.Debug(ctx, "failed to clear pagination reactions", slog.Error(err), slog.F("channel_id", p.msg.ChannelID))
+	}
+}
+
+// renderPage loads page i of src and adds the page number to its footer.
+func renderPage(ctx context.Context, src PageSource, i int) (*disgord.Embed, error) {
+	page, err := src.Page(ctx, i)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load page %d: %w", i+1, err)
+	}
+	if src.Len() == 1 {
+		return page, nil
+	}
+
+	embed := page.DeepCopy().(*disgord.Embed)
+	number := fmt.Sprintf("Page %d/%d", i+1, src.Len())
+	if embed.Footer == nil {
+		embed.Footer = &disgord.EmbedFooter{}
+	}
+	if embed.Footer.Text != "" {
+		number = embed.Footer.Text + " • " + number
+	}
+	embed.Footer.Text = number
+
+	return embed, nil
+}
@@ -0,0 +1,95 @@
+package rikka_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+func TestPaginate(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register()
+
+	var loaded []int
+	src := rikka.PageFunc(3, func(ctx context.Context, i int) (*disgord.Embed, error) {
+		loaded = append(loaded, i)
+		return &disgord.Embed{Title: "page " + strconv.Itoa(i+1)}, nil
+	})
+
+	invocation := h.NewMessage("r.list")
+	err := h.Rikka.Paginate(context.Background(), h.Session, invocation, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := h.Session.Sent()
+	msg := sent[len(sent)-1]
+	page := func() string { return msg.Embeds[0].Title + " " + msg.Embeds[0].Footer.Text }
+
+	if got := page(); got != "page 1 Page 1/3" {
+		t.Fatalf("first page = %q", got)
+	}
+	for _, e := range []string{rikka.PagePrevious, rikka.PageNext, rikka.PageStop} {
+		if !msg.HasReaction(e, 1) {
+			t.Fatalf("missing %s reaction", e)
+		}
+	}
+
+	h.ExpectNoReply(h.React(h.Owner, msg.Message, rikka.PageNext))
+	if got := page(); got != "page 1 Page 1/3" {
+		t.Fatalf("page changed by another user: %q", got)
+	}
+	if msg.HasReaction(rikka.PageNext, h.Owner.ID) {
+		t.Fatal("reaction of another user was not removed")
+	}
+
+	h.React(h.User, msg.Message, rikka.PageNext)
+	if got := page(); got != "page 2 Page 2/3" {
+		t.Fatalf("next page = %q", got)
+	}
+
+	h.React(h.User, msg.Message, rikka.PagePrevious)
+	h.React(h.User, msg.Message, rikka.PagePrevious)
+	if got := page(); got != "page 3 Page 3/3" {
+		t.Fatalf("previous page did not wrap around: %q", got)
+	}
+	if len(loaded) != 4 {
+		t.Fatalf("loaded %d pages, want 4", len(loaded))
+	}
+
+	h.React(h.User, msg.Message, rikka.PageStop)
+	if len(msg.Reactions) != 0 {
+		t.Fatalf("reactions left after stopping: %v", msg.Reactions)
+	}
+	h.React(h.User, msg.Message, rikka.PageNext)
+	if got := page(); got != "page 3 Page 3/3" {
+		t.Fatalf("page changed after stopping: %q", got)
+	}
+}
+
+func TestPaginateTimeout(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register()
+	h.Rikka.PaginatorTimeout = 10 * time.Millisecond
+
+	err := h.Rikka.Paginate(context.Background(), h.Session, h.NewMessage("r.list"), rikka.EmbedPages{{Title: "a"}, {Title: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := h.Session.Sent()
+	msg := sent[len(sent)-1]
+	deadline := time.Now().Add(time.Second)
+	for len(h.Session.Reactions(msg.ID)) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("reactions were not removed after the timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,437 @@
+package rikka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+var permissionNames = []struct {
+	bit  disgord.PermissionBit
+	name string
+}{
+	{disgord.PermissionAdministrator, "Administrator"},
+	{disgord.PermissionManageServer, "Manage Server"},
+	{disgord.PermissionManageRoles, "Manage Roles"},
+	{disgord.PermissionManageChannels, "Manage Channels"},
+	{disgord.PermissionManageMessages, "Manage Messages"},
+	{disgord.PermissionManageNicknames, "Manage Nicknames"},
+	{disgord.PermissionManageWebhooks, "Manage Webhooks"},
+	{disgord.PermissionManageEmojis, "Manage Emojis"},
+	{disgord.PermissionViewAuditLogs, "View Audit Log"},
+	{disgord.PermissionKickMembers, "Kick Members"},
+	{disgord.PermissionBanMembers, "Ban Members"},
+	{disgord.PermissionCreateInstantInvite, "Create Invite"},
+	{disgord.PermissionChangeNickname, "Change Nickname"},
+	{disgord.PermissionReadMessages, "Read Messages"},
+	{disgord.PermissionSendMessages, "Send Messages"},
+	{disgord.PermissionSendTTSMessages, "Send TTS Messages"},
+	{disgord.PermissionEmbedLinks, "Embed Links"},
+	{disgord.PermissionAttachFiles, "Attach Files"},
+	{disgord.PermissionReadMessageHistory, "Read Message History"},
+	{disgord.PermissionMentionEveryone, "Mention Everyone"},
+	{disgord.PermissionUseExternalEmojis, "Use External Emojis"},
+	{disgord.PermissionAddReactions, "Add Reactions"},
+	{disgord.PermissionVoiceConnect, "Connect"},
+	{disgord.PermissionVoiceSpeak, "Speak"},
+	{disgord.PermissionVoiceMuteMembers, "Mute Members"},
+	{disgord.PermissionVoiceDeafenMembers, "Deafen Members"},
+	{disgord.PermissionVoiceMoveMembers, "Move Members"},
+	{disgord.PermissionVoiceUseVAD, "Use Voice Activity"},
+	{disgord.PermissionVoicePrioritySpeaker, "Priority Speaker"},
+}
+
+// PermissionNames returns the human readable names of each permission set in
+// bits.
+func PermissionNames(bits disgord.PermissionBits) []string {
+	var names []string
+	for _, e := range permissionNames {
+		if bits&e.bit != 0 {
+			names = append(names, e.name)
+		}
+	}
+
+	return names
+}
+
+// PermissionTarget is what a permission override applies to.
+type PermissionTarget int
+
+const (
+	PermissionTargetUser PermissionTarget = iota
+	PermissionTargetRole
+	PermissionTargetChannel
+)
+
+func (t PermissionTarget) String() string {
+	switch t {
+	case PermissionTargetUser:
+		return "User"
+	case PermissionTargetRole:
+		return "Role"
+	case PermissionTargetChannel:
+		return "Channel"
+	default:
+		panic("unknown permission target: " + strconv.FormatInt(int64(t), 10))
+	}
+}
+
+// Mention formats id as a mention of the target type.
+func (t PermissionTarget) Mention(id disgord.Snowflake) string {
+	switch t {
+	case PermissionTargetRole:
+		return "<@&" + id.String() + ">"
+	case PermissionTargetChannel:
+		return "<#" + id.String() + ">"
+	default:
+		return "<@" + id.String() + ">"
+	}
+}
+
+// PermissionOverride grants or denies a command to a user, role or channel in
+// a guild, regardless of the Discord permissions the command requires.
+type PermissionOverride struct {
+	Command string
+	Target  PermissionTarget
+	ID      disgord.Snowflake
+	Allow   bool
+}
+
+// permissions stores the permission overrides of each guild.
+type permissions struct {
+	dir storage.Subspace
+
+	mu    sync.RWMutex
+	cache map[disgord.Snowflake][]PermissionOverride
+}
+
+// PermissionOverrides returns all permission overrides set in a guild.
+func (r *Rikka) PermissionOverrides(guildID disgord.Snowflake) ([]PermissionOverride, error) {
+	r.permissions.mu.RLock()
+	cached, ok := r.permissions.cache[guildID]
+	r.permissions.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	var overrides []PermissionOverride
+	err := r.ReadTransact(func(t storage.ReadTransaction) error {
+		overrides = nil
+		kvs, err := t.Snapshot().GetRange(r.permissions.dir.Sub(uint64(guildID)).Range(), storage.RangeOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range kvs {
+			tup, err := r.permissions.dir.Unpack(kv.Key)
+			if err != nil {
+				return xerrors.Errorf("failed to unpack permission override key: %w", err)
+			}
+
+			command, _ := tup[1].(string)
+			target, _ := tup[2].(int64)
+			overrides = append(overrides, PermissionOverride{
+				Command: command,
+				Target:  PermissionTarget(target),
+				ID:      tupleSnowflake(tup[3]),
+				Allow:   len(kv.Value) > 0 && kv.Value[0] == 1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to transact permission overrides: %w", err)
+	}
+
+	r.permissions.mu.Lock()
+	r.permissions.cache[guildID] = overrides
+	r.permissions.mu.Unlock()
+
+	return overrides, nil
+}
+
+// SetPermissionOverride adds or replaces a permission override in a guild.
+func (r *Rikka) SetPermissionOverride(guildID disgord.Snowflake, o PermissionOverride) error {
+	var allow byte
+	if o.Allow {
+		allow = 1
+	}
+
+	err := r.Transact(func(t storage.Transaction) error {
+		t.Set(r.fmtPermissionOverrideKey(guildID, o.Command, o.Target, o.ID), []byte{allow})
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact permission override: %w", err)
+	}
+
+	r.invalidatePermissions(guildID)
+	return nil
+}
+
+// ClearPermissionOverride removes a permission override from a guild.
+func (r *Rikka) ClearPermissionOverride(guildID disgord.Snowflake, command string, target PermissionTarget, id disgord.Snowflake) error {
+	err := r.Transact(func(t storage.Transaction) error {
+		t.Clear(r.fmtPermissionOverrideKey(guildID, command, target, id))
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact permission override removal: %w", err)
+	}
+
+	r.invalidatePermissions(guildID)
+	return nil
+}
+
+func (r *Rikka) invalidatePermissions(guildID disgord.Snowflake) {
+	r.permissions.mu.Lock()
+	delete(r.permissions.cache, guildID)
+	r.permissions.mu.Unlock()
+}
+
+func (r *Rikka) fmtPermissionOverrideKey(guildID disgord.Snowflake, command string, target PermissionTarget, id disgord.Snowflake) storage.Key {
+	return r.permissions.dir.Pack(tuple.Tuple{uint64(guildID), command, int64(target), uint64(id)})
+}
+
+// MemberPermissions returns the guild wide permissions of a member. Guild
+// owners and administrators have every permission.
+func (r *Rikka) MemberPermissions(ctx context.Context, s disgord.Session, guildID, userID disgord.Snowflake) (disgord.PermissionBits, error) {
+	guild, err := s.GetGuild(ctx, guildID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get guild: %w", err)
+	}
+
+	if guild.OwnerID == userID {
+		return disgord.PermissionAll, nil
+	}
+
+	member, err := s.GetMember(ctx, guildID, userID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get member: %w", err)
+	}
+
+	var perms disgord.PermissionBits
+	for _, role := range guild.Roles {
+		if role.ID == guildID || hasSnowflake(member.Roles, role.ID) {
+			perms |= role.Permissions
+		}
+	}
+
+	if perms&disgord.PermissionAdministrator != 0 {
+		return disgord.PermissionAll, nil
+	}
+
+	return perms, nil
+}
+
+// ChannelPermissions returns the permissions of a member in a channel, which
+// are their guild wide permissions with the channel's overwrites for
+// @everyone, then their roles, then the member applied.
+func (r *Rikka) ChannelPermissions(ctx context.Context, s disgord.Session, guildID, channelID, userID disgord.Snowflake) (disgord.PermissionBits, error) {
+	perms, err := r.MemberPermissions(ctx, s, guildID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if perms&disgord.PermissionAdministrator != 0 {
+		return perms, nil
+	}
+
+	channel, err := s.GetChannel(ctx, channelID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get channel: %w", err)
+	}
+
+	member, err := s.GetMember(ctx, guildID, userID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get member: %w", err)
+	}
+
+	var (
+		everyone, user      *disgord.PermissionOverwrite
+		roleAllow, roleDeny disgord.PermissionBits
+	)
+	for i, e := range channel.PermissionOverwrites {
+		switch {
+		case e.Type == "role" && e.ID == guildID:
+			everyone = &channel.PermissionOverwrites[i]
+		case e.Type == "role" && hasSnowflake(member.Roles, e.ID):
+			roleAllow |= e.Allow
+			roleDeny |= e.Deny
+		case e.Type == "member" && e.ID == userID:
+			user = &channel.PermissionOverwrites[i]
+		}
+	}
+
+	if everyone != nil {
+		perms = perms&^everyone.Deny | everyone.Allow
+	}
+	perms = perms&^roleDeny | roleAllow
+	if user != nil {
+		perms = perms&^user.Deny | user.Allow
+	}
+
+	return perms, nil
+}
+
+// HasPermissions returns true if the author of msg has all of perms in the
+// channel the message was sent in.
+func (r *Rikka) HasPermissions(ctx context.Context, s disgord.Session, msg *disgord.Message, perms disgord.PermissionBits) (bool, error) {
+	if msg.GuildID.IsZero() || msg.Author == nil {
+		return false, nil
+	}
+
+	has, err := r.ChannelPermissions(ctx, s, msg.GuildID, msg.ChannelID, msg.Author.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return has&perms == perms, nil
+}
+
+// canRun decides whether the author of msg may run the command described by
+// help. Administrators and bot owners may always run commands. Otherwise permission
+// overrides are checked from most to least specific: user, channel, then
+// role. Without a matching override the author must have the Discord
+// permissions the command requires. If the command may not be run, reason is
+// a message that can be shown to the user.
+func (r *Rikka) canRun(ctx context.Context, s disgord.Session, msg *disgord.Message, help CommandHelp) (ok bool, reason string, err error) {
+	if msg.GuildID.IsZero() {
+		if help.Permissions != 0 {
+			return false, "This command can only be used in servers", nil
+		}
+		return true, "", nil
+	}
+
+	if msg.Author != nil && r.IsOwner(msg.Author.ID) {
+		return true, "", nil
+	}
+
+	overrides, err := r.PermissionOverrides(msg.GuildID)
+	if err != nil {
+		return false, "", err
+	}
+
+	override, found, err := r.matchOverride(ctx, s, msg, help.Name, overrides)
+	if err != nil {
+		return false, "", err
+	}
+
+	if found && override.Allow {
+		return true, "", nil
+	}
+
+	if !found && help.Permissions == 0 {
+		return true, "", nil
+	}
+
+	perms, err := r.ChannelPermissions(ctx, s, msg.GuildID, msg.ChannelID, msg.Author.ID)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch {
+	case perms&disgord.PermissionAdministrator != 0:
+		return true, "", nil
+	case found:
+		return false, fmt.Sprintf("You are not allowed to use `%s` here", help.Name), nil
+	case perms&help.Permissions != help.Permissions:
+		return false, fmt.Sprintf("You need the %s permission to use `%s`",
+			strings.Join(PermissionNames(help.Permissions), ", "),
+			help.Name,
+		), nil
+	}
+
+	return true, "", nil
+}
+
+// matchOverride finds the most specific override of command that applies to
+// msg.
+func (r *Rikka) matchOverride(ctx context.Context, s disgord.Session, msg *disgord.Message, command string, overrides []PermissionOverride) (PermissionOverride, bool, error) {
+	var (
+		channel   *PermissionOverride
+		roles     []PermissionOverride
+		roleAllow bool
+	)
+
+	for i, e := range overrides {
+		if e.Command != command {
+			continue
+		}
+
+		switch e.Target {
+		case PermissionTargetUser:
+			if e.ID == msg.Author.ID {
+				return e, true, nil
+			}
+		case PermissionTargetChannel:
+			if e.ID == msg.ChannelID {
+				channel = &overrides[i]
+			}
+		case PermissionTargetRole:
+			roles = append(roles, e)
+		}
+	}
+
+	if channel != nil {
+		return *channel, true, nil
+	}
+
+	if len(roles) == 0 {
+		return PermissionOverride{}, false, nil
+	}
+
+	memberRoles, err := r.memberRoles(ctx, s, msg)
+	if err != nil {
+		return PermissionOverride{}, false, err
+	}
+
+	// An allowing role takes precedence over a denying one, so a member can
+	// be granted a command through any of their roles.
+	var (
+		match PermissionOverride
+		found bool
+	)
+	for _, e := range roles {
+		if e.ID != msg.GuildID && !hasSnowflake(memberRoles, e.ID) {
+			continue
+		}
+
+		if !found || (e.Allow && !roleAllow) {
+			match, found, roleAllow = e, true, e.Allow
+		}
+	}
+
+	return match, found, nil
+}
+
+func (r *Rikka) memberRoles(ctx context.Context, s disgord.Session, msg *disgord.Message) ([]disgord.Snowflake, error) {
+	if msg.Member != nil {
+		return msg.Member.Roles, nil
+	}
+
+	member, err := s.GetMember(ctx, msg.GuildID, msg.Author.ID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get member: %w", err)
+	}
+
+	return member.Roles, nil
+}
+
+func hasSnowflake(ids []disgord.Snowflake, id disgord.Snowflake) bool {
+	for _, e := range ids {
+		if e == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,234 @@
+package rikka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/fake"
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestCanRun(t *testing.T) {
+	const (
+		guildID   disgord.Snowflake = 10
+		channelID disgord.Snowflake = 20
+		otherChan disgord.Snowflake = 21
+		modRole   disgord.Snowflake = 30
+		mutedRole disgord.Snowflake = 31
+		adminRole disgord.Snowflake = 32
+		ownerID   disgord.Snowflake = 40
+		userID    disgord.Snowflake = 41
+	)
+
+	s := fake.NewSession(&disgord.User{ID: 1, Username: "rikka", Bot: true})
+	s.AddGuild(&disgord.Guild{
+		ID:      guildID,
+		OwnerID: ownerID,
+		Roles: []*disgord.Role{
+			{ID: guildID, Name: "@everyone", Permissions: disgord.PermissionSendMessages},
+			{ID: modRole, Name: "mod", Permissions: disgord.PermissionManageMessages},
+			{ID: mutedRole, Name: "muted"},
+			{ID: adminRole, Name: "admin", Permissions: disgord.PermissionAdministrator},
+		},
+	})
+
+	var (
+		purge = CommandHelp{Name: "purge", Permissions: disgord.PermissionManageMessages}
+		ping  = CommandHelp{Name: "ping"}
+	)
+
+	tests := []struct {
+		name       string
+		help       CommandHelp
+		dm         bool
+		botOwner   bool
+		roles      []disgord.Snowflake
+		overwrites []disgord.PermissionOverwrite
+		overrides  []PermissionOverride
+		want       bool
+	}{
+		{name: "no permissions needed", help: ping, want: true},
+		{name: "missing permission", help: purge, want: false},
+		{name: "has permission", help: purge, roles: []disgord.Snowflake{modRole}, want: true},
+		{name: "administrator", help: purge, roles: []disgord.Snowflake{adminRole}, want: true},
+		{
+			name:      "administrator ignores deny",
+			help:      ping,
+			roles:     []disgord.Snowflake{adminRole},
+			overrides: []PermissionOverride{{Command: "ping", Target: PermissionTargetUser, ID: userID}},
+			want:      true,
+		},
+		{
+			name:      "bot owner",
+			help:      purge,
+			botOwner:  true,
+			overrides: []PermissionOverride{{Command: "purge", Target: PermissionTargetUser, ID: userID}},
+			want:      true,
+		},
+		{name: "bot owner in dm", help: purge, dm: true, botOwner: true, want: false},
+		{name: "dm without permissions", help: ping, dm: true, want: true},
+		{name: "dm with permissions", help: purge, dm: true, want: false},
+		{
+			name:      "user allow",
+			help:      purge,
+			overrides: []PermissionOverride{{Command: "purge", Target: PermissionTargetUser, ID: userID, Allow: true}},
+			want:      true,
+		},
+		{
+			name:      "user deny",
+			help:      ping,
+			overrides: []PermissionOverride{{Command: "ping", Target: PermissionTargetUser, ID: userID}},
+			want:      false,
+		},
+		{
+			name: "user beats channel",
+			help: ping,
+			overrides: []PermissionOverride{
+				{Command: "ping", Target: PermissionTargetChannel, ID: channelID},
+				{Command: "ping", Target: PermissionTargetUser, ID: userID, Allow: true},
+			},
+			want: true,
+		},
+		{
+			name:  "channel beats role",
+			help:  purge,
+			roles: []disgord.Snowflake{modRole},
+			overrides: []PermissionOverride{
+				{Command: "purge", Target: PermissionTargetRole, ID: modRole, Allow: true},
+				{Command: "purge", Target: PermissionTargetChannel, ID: channelID},
+			},
+			want: false,
+		},
+		{
+			name:      "channel override of another channel",
+			help:      ping,
+			overrides: []PermissionOverride{{Command: "ping", Target: PermissionTargetChannel, ID: otherChan}},
+			want:      true,
+		},
+		{
+			name:      "role allow",
+			help:      purge,
+			roles:     []disgord.Snowflake{mutedRole},
+			overrides: []PermissionOverride{{Command: "purge", Target: PermissionTargetRole, ID: mutedRole, Allow: true}},
+			want:      true,
+		},
+		{
+			name:      "everyone role deny",
+			help:      ping,
+			overrides: []PermissionOverride{{Command: "ping", Target: PermissionTargetRole, ID: guildID}},
+			want:      false,
+		},
+		{
+			name:  "allow role beats deny role",
+			help:  ping,
+			roles: []disgord.Snowflake{modRole, mutedRole},
+			overrides: []PermissionOverride{
+				{Command: "ping", Target: PermissionTargetRole, ID: mutedRole},
+				{Command: "ping", Target: PermissionTargetRole, ID: modRole, Allow: true},
+			},
+			want: true,
+		},
+		{
+			name:      "role the member doesn't have",
+			help:      ping,
+			roles:     []disgord.Snowflake{modRole},
+			overrides: []PermissionOverride{{Command: "ping", Target: PermissionTargetRole, ID: mutedRole}},
+			want:      true,
+		},
+		{
+			name:       "channel overwrite denies role",
+			help:       purge,
+			roles:      []disgord.Snowflake{modRole},
+			overwrites: []disgord.PermissionOverwrite{{ID: modRole, Type: "role", Deny: disgord.PermissionManageMessages}},
+			want:       false,
+		},
+		{
+			name:       "channel overwrite allows member",
+			help:       purge,
+			overwrites: []disgord.PermissionOverwrite{{ID: userID, Type: "member", Allow: disgord.PermissionManageMessages}},
+			want:       true,
+		},
+		{
+			name:  "role overwrite beats everyone overwrite",
+			help:  purge,
+			roles: []disgord.Snowflake{mutedRole},
+			overwrites: []disgord.PermissionOverwrite{
+				{ID: guildID, Type: "role", Allow: disgord.PermissionManageMessages},
+				{ID: mutedRole, Type: "role", Deny: disgord.PermissionManageMessages},
+			},
+			want: false,
+		},
+		{
+			name:  "member overwrite beats role overwrite",
+			help:  purge,
+			roles: []disgord.Snowflake{mutedRole},
+			overwrites: []disgord.PermissionOverwrite{
+				{ID: mutedRole, Type: "role", Deny: disgord.PermissionManageMessages},
+				{ID: userID, Type: "member", Allow: disgord.PermissionManageMessages},
+			},
+			want: true,
+		},
+		{
+			name:       "overwrite of a role the member doesn't have",
+			help:       purge,
+			roles:      []disgord.Snowflake{modRole},
+			overwrites: []disgord.PermissionOverwrite{{ID: mutedRole, Type: "role", Deny: disgord.PermissionManageMessages}},
+			want:       true,
+		},
+		{
+			name:       "administrator ignores overwrites",
+			help:       purge,
+			roles:      []disgord.Snowflake{adminRole},
+			overwrites: []disgord.PermissionOverwrite{{ID: guildID, Type: "role", Deny: disgord.PermissionAll}},
+			want:       true,
+		},
+		{
+			name:      "override of another command",
+			help:      ping,
+			overrides: []PermissionOverride{{Command: "purge", Target: PermissionTargetUser, ID: userID}},
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Token = "test"
+			if tt.botOwner {
+				cfg.Owners = []disgord.Snowflake{userID}
+			}
+			r := New(storage.NewMemory(), cfg)
+
+			guild := guildID
+			if tt.dm {
+				guild = 0
+			}
+			for _, e := range tt.overrides {
+				if err := r.SetPermissionOverride(guildID, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s.AddChannel(&disgord.Channel{ID: channelID, GuildID: guildID, PermissionOverwrites: tt.overwrites})
+			s.AddMember(guildID, &disgord.Member{User: &disgord.User{ID: userID}, Roles: tt.roles})
+			msg := &disgord.Message{
+				GuildID:   guild,
+				ChannelID: channelID,
+				Author:    &disgord.User{ID: userID},
+			}
+
+			got, reason, err := r.canRun(context.Background(), s, msg, tt.help)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canRun() = %v (%q), want %v", got, reason, tt.want)
+			}
+			if !got && reason == "" {
+				t.Error("denied without a reason")
+			}
+		})
+	}
+}
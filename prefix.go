@@ -0,0 +1,183 @@
+package rikka
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+const (
+	// MaxPrefixes is the maximum amount of prefixes a guild may set.
+	MaxPrefixes = 5
+	// MaxPrefixLength is the maximum length of a single prefix.
+	MaxPrefixLength = 16
+)
+
+// prefixes stores the command prefixes of each guild. Guilds without custom
+// prefixes use Rikka.Prefix.
+type prefixes struct {
+	dir storage.Subspace
+
+	mu    sync.RWMutex
+	cache map[disgord.Snowflake][]string
+	// gens is incremented every time a guild's prefixes change, so a fill
+	// that read them before the change isn't cached.
+	gens map[disgord.Snowflake]uint64
+}
+
+// Prefixes returns the command prefixes for a guild, sorted from longest to
+// shortest.
+func (r *Rikka) Prefixes(guildID disgord.Snowflake) []string {
+	if guildID.IsZero() {
+		return []string{r.Prefix}
+	}
+
+	r.prefixes.mu.RLock()
+	cached, ok := r.prefixes.cache[guildID]
+	gen := r.prefixes.gens[guildID]
+	r.prefixes.mu.RUnlock()
+	if ok {
+		return r.prefixesOrDefault(cached)
+	}
+
+	var raw []byte
+	err := r.ReadTransact(func(t storage.ReadTransaction) error {
+		var err error
+		raw, err = t.Snapshot().Get(r.fmtPrefixKey(guildID))
+		return err
+	})
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to load guild prefixes", slog.Error(err), slog.F("guild_id", guildID))
+		return []string{r.Prefix}
+	}
+
+	var loaded []string
+	if raw != nil {
+		tup, err := tuple.Unpack(raw)
+		if err != nil {
+			r.Log.Error(r.ctx, "failed to unpack guild prefixes", slog.Error(err), slog.F("guild_id", guildID))
+			return []string{r.Prefix}
+		}
+
+		for _, e := range tup {
+			if p, ok := e.(string); ok {
+				loaded = append(loaded, p)
+			}
+		}
+		sortPrefixes(loaded)
+	}
+
+	r.cachePrefixes(guildID, gen, loaded)
+	return r.prefixesOrDefault(loaded)
+}
+
+// cachePrefixes caches the prefixes of a guild, unless they changed since gen
+// was read.
+func (r *Rikka) cachePrefixes(guildID disgord.Snowflake, gen uint64, prefixes []string) {
+	r.prefixes.mu.Lock()
+	defer r.prefixes.mu.Unlock()
+
+	if r.prefixes.gens[guildID] != gen {
+		return
+	}
+	r.prefixes.cache[guildID] = prefixes
+}
+
+func (r *Rikka) prefixesOrDefault(prefixes []string) []string {
+	if len(prefixes) == 0 {
+		return []string{r.Prefix}
+	}
+
+	return prefixes
+}
+
+// SetPrefixes replaces the command prefixes for a guild.
+func (r *Rikka) SetPrefixes(guildID disgord.Snowflake, prefixes []string) error {
+	if len(prefixes) == 0 {
+		return BadInput("At least one prefix is required")
+	}
+	if len(prefixes) > MaxPrefixes {
+		return BadInput("At most %d prefixes may be set", MaxPrefixes)
+	}
+
+	tup := make(tuple.Tuple, 0, len(prefixes))
+	for _, e := range prefixes {
+		if e == "" || len(e) > MaxPrefixLength || strings.ContainsAny(e, " \t\n") {
+			return BadInput("Invalid prefix `%s`, prefixes must be 1-%d characters without whitespace", e, MaxPrefixLength)
+		}
+		tup = append(tup, e)
+	}
+
+	err := r.Transact(func(t storage.Transaction) error {
+		t.Set(r.fmtPrefixKey(guildID), tup.Pack())
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact guild prefixes: %w", err)
+	}
+
+	r.invalidatePrefixes(guildID)
+	return nil
+}
+
+// ResetPrefixes restores the default command prefix for a guild.
+func (r *Rikka) ResetPrefixes(guildID disgord.Snowflake) error {
+	err := r.Transact(func(t storage.Transaction) error {
+		t.Clear(r.fmtPrefixKey(guildID))
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact guild prefix reset: %w", err)
+	}
+
+	r.invalidatePrefixes(guildID)
+	return nil
+}
+
+func (r *Rikka) invalidatePrefixes(guildID disgord.Snowflake) {
+	r.prefixes.mu.Lock()
+	delete(r.prefixes.cache, guildID)
+	r.prefixes.gens[guildID]++
+	r.prefixes.mu.Unlock()
+}
+
+// stripPrefix removes a guild prefix or a mention of the bot from the start of
+// message. ok is false if the message starts with neither.
+func (r *Rikka) stripPrefix(guildID disgord.Snowflake, message string) (rest string, ok bool) {
+	message = strings.TrimSpace(message)
+
+	if r.self != nil {
+		for _, mention := range []string{"<@" + r.self.ID.String() + ">", "<@!" + r.self.ID.String() + ">"} {
+			if strings.HasPrefix(message, mention) {
+				return strings.TrimSpace(message[len(mention):]), true
+			}
+		}
+	}
+
+	for _, prefix := range r.Prefixes(guildID) {
+		if len(message) >= len(prefix) && strings.EqualFold(message[:len(prefix)], prefix) {
+			return message[len(prefix):], true
+		}
+	}
+
+	return "", false
+}
+
+// sortPrefixes sorts prefixes from longest to shortest so that a prefix is
+// never shadowed by a shorter one it begins with.
+func sortPrefixes(prefixes []string) {
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+}
+
+func (r *Rikka) fmtPrefixKey(guildID disgord.Snowflake) storage.Key {
+	return r.prefixes.dir.Pack(tuple.Tuple{uint64(guildID)})
+}
@@ -0,0 +1,51 @@
+package rikka
+
+import (
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestPrefixesStored(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+
+	const guild = disgord.Snowflake(1)
+	err := r.SetPrefixes(guild, []string{"!", "rikka."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the cache so the prefixes are read back from the store.
+	r.prefixes.cache = map[disgord.Snowflake][]string{}
+	got := r.Prefixes(guild)
+	if len(got) != 2 || got[0] != "rikka." || got[1] != "!" {
+		t.Fatalf("prefixes = %q", got)
+	}
+
+	err = r.ResetPrefixes(guild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.prefixes.cache = map[disgord.Snowflake][]string{}
+	if got := r.Prefixes(guild); len(got) != 1 || got[0] != cfg.Prefix {
+		t.Fatalf("prefixes after reset = %q", got)
+	}
+
+	// A fill that read the prefixes before they changed isn't cached.
+	r.prefixes.mu.RLock()
+	gen := r.prefixes.gens[guild]
+	r.prefixes.mu.RUnlock()
+
+	err = r.SetPrefixes(guild, []string{"!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.cachePrefixes(guild, gen, nil)
+	if got := r.Prefixes(guild); len(got) != 1 || got[0] != "!" {
+		t.Fatalf("prefixes after a stale fill = %q", got)
+	}
+}
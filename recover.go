@@ -0,0 +1,94 @@
+package rikka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+)
+
+// newErrorID returns a short random id that can be shown to users and
+// searched for in the logs.
+func newErrorID() string {
+	var raw [4]byte
+	_, _ = rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
+
+// reportPanic logs a panic recovered from an event handler, tells the user
+// something went wrong and forwards the report to the error channel if one is
+// configured.
+func (r *Rikka) reportPanic(event string, s disgord.Session, evt interface{}, rec interface{}, stack []byte) {
+	var (
+		id = newErrorID()
+		e  = NewEvent(nil, evt)
+	)
+
+	ctx, cancel := context.WithTimeout(r.ctx, 10*time.Second)
+	defer cancel()
+
+	r.Log.Critical(ctx, "recovered panic in event handler",
+		slog.F("error_id", id),
+		slog.F("event", event),
+		slog.F("guild_id", e.GuildID),
+		slog.F("channel_id", e.ChannelID),
+		slog.F("user_id", e.UserID),
+		slog.F("panic", fmt.Sprint(rec)),
+		slog.F("stack", string(stack)),
+	)
+
+	if mc, ok := evt.(*disgord.MessageCreate); ok {
+		countCommandError(mc.Ctx, "panic")
+	}
+
+	if s == nil {
+		return
+	}
+
+	if _, ok := evt.(*disgord.MessageCreate); ok && !e.ChannelID.IsZero() {
+		_, err := s.SendMsg(ctx, e.ChannelID, fmt.Sprintf("Something went wrong. If this keeps happening, please report error `%s`.", id))
+		if err != nil {
+			r.Log.Error(ctx, "failed to send panic message to channel", slog.Error(err), slog.F("error_id", id))
+		}
+	}
+
+	if r.ErrorChannel.IsZero() {
+		return
+	}
+
+	const maxStack = 1800
+	if len(stack) > maxStack {
+		stack = stack[:maxStack]
+	}
+
+	_, err := s.SendMsg(ctx, r.ErrorChannel, disgord.CreateMessageParams{
+		Embed: &disgord.Embed{
+			Title:       "Panic in " + event + " handler",
+			Description: "```\n" + string(stack) + "\n```",
+			Color:       0xe74c3c,
+			Fields: []*disgord.EmbedField{
+				{Name: "Error ID", Value: id, Inline: true},
+				{Name: "Guild", Value: e.GuildID.String(), Inline: true},
+				{Name: "Channel", Value: e.ChannelID.String(), Inline: true},
+				{Name: "User", Value: e.UserID.String(), Inline: true},
+				{Name: "Panic", Value: truncate(fmt.Sprint(rec), 1024)},
+			},
+			Timestamp: disgord.Time{Time: time.Now()},
+		},
+	})
+	if err != nil {
+		r.Log.Error(ctx, "failed to forward panic report", slog.Error(err), slog.F("error_id", id))
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	return s[:max-3] + "..."
+}
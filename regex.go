@@ -10,6 +10,7 @@ import (
 
 var UserMentionRegex = regexp.MustCompile(`^<@!?(\d+)>$`)
 var ChannelMentionRegex = regexp.MustCompile(`^<#!?(\d+)>$`)
+var RoleMentionRegex = regexp.MustCompile(`^<@&(\d+)>$`)
 
 func ExtractID(reg *regexp.Regexp, s string) (disgord.Snowflake, error) {
 	var (
@@ -0,0 +1,286 @@
+package rikka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+)
+
+// ResponseWindow is how long after a command was sent editing it re-runs the
+// command, and deleting it deletes the bot's responses.
+const ResponseWindow = 5 * time.Minute
+
+// responses tracks the messages sent in response to recent commands.
+type responses struct {
+	mu        sync.Mutex
+	byCommand map[disgord.Snowflake]*invocation
+	lastSweep time.Time
+}
+
+// invocation is a message that invoked a command and the responses to it.
+type invocation struct {
+	at      time.Time
+	author  *disgord.User
+	content string
+	sent    []response
+}
+
+// response is a message sent while handling a command.
+type response struct {
+	channelID disgord.Snowflake
+	messageID disgord.Snowflake
+	files     bool
+}
+
+// responseSession records the messages a command sends. When a command is
+// re-run, its previous responses are edited in order instead of sending new
+// messages.
+type responseSession struct {
+	disgord.Session
+	r   *Rikka
+	msg *disgord.Message
+
+	mu       sync.Mutex
+	sent     []response
+	previous []response
+}
+
+// trackResponses returns a session that records the responses to msg. If msg
+// was already handled, its previous responses are reused. finish must be
+// called once the command has returned.
+func (r *Rikka) trackResponses(s disgord.Session, msg *disgord.Message) *responseSession {
+	rs := &responseSession{Session: s, r: r, msg: msg}
+
+	r.responses.mu.Lock()
+	if inv, ok := r.responses.byCommand[msg.ID]; ok {
+		rs.previous = inv.sent
+		inv.sent = nil
+	}
+	r.responses.mu.Unlock()
+
+	return rs
+}
+
+// finish saves the responses sent while handling the command and deletes the
+// previous responses that weren't reused.
+func (rs *responseSession) finish() {
+	rs.mu.Lock()
+	sent, unused := rs.sent, rs.previous
+	rs.mu.Unlock()
+
+	r := rs.r
+	r.responses.mu.Lock()
+	now := time.Now()
+	if now.Sub(r.responses.lastSweep) > ResponseWindow {
+		for id, e := range r.responses.byCommand {
+			if now.Sub(e.at) > ResponseWindow {
+				delete(r.responses.byCommand, id)
+			}
+		}
+		r.responses.lastSweep = now
+	}
+
+	inv, ok := r.responses.byCommand[rs.msg.ID]
+	if !ok {
+		if r.responses.byCommand == nil {
+			r.responses.byCommand = map[disgord.Snowflake]*invocation{}
+		}
+		inv = &invocation{at: now, author: rs.msg.Author}
+		r.responses.byCommand[rs.msg.ID] = inv
+	}
+	inv.content = rs.msg.Content
+	inv.sent = append(inv.sent, sent...)
+	r.responses.mu.Unlock()
+
+	r.deleteResponses(rs.Session, unused)
+}
+
+func (rs *responseSession) SendMsg(ctx context.Context, channelID disgord.Snowflake, data ...interface{}) (*disgord.Message, error) {
+	if params, ok := editableParams(data); ok {
+		if msg, ok := rs.editPrevious(ctx, channelID, params); ok {
+			return msg, nil
+		}
+	}
+
+	msg, err := rs.Session.SendMsg(ctx, channelID, data...)
+	if err == nil {
+		rs.record(msg, hasFiles(data))
+	}
+
+	return msg, err
+}
+
+func (rs *responseSession) CreateMessage(ctx context.Context, channelID disgord.Snowflake, params *disgord.CreateMessageParams, flags ...disgord.Flag) (*disgord.Message, error) {
+	if len(params.Files) == 0 {
+		if msg, ok := rs.editPrevious(ctx, channelID, params); ok {
+			return msg, nil
+		}
+	}
+
+	msg, err := rs.Session.CreateMessage(ctx, channelID, params, flags...)
+	if err == nil {
+		rs.record(msg, len(params.Files) > 0)
+	}
+
+	return msg, err
+}
+
+func (rs *responseSession) record(msg *disgord.Message, files bool) {
+	rs.mu.Lock()
+	rs.sent = append(rs.sent, response{channelID: msg.ChannelID, messageID: msg.ID, files: files})
+	rs.mu.Unlock()
+}
+
+// editPrevious replaces the next previous response with params. ok is false
+// if there is none that can be edited, in which case a new message must be
+// sent.
+func (rs *responseSession) editPrevious(ctx context.Context, channelID disgord.Snowflake, params *disgord.CreateMessageParams) (msg *disgord.Message, ok bool) {
+	rs.mu.Lock()
+	if len(rs.previous) == 0 {
+		rs.mu.Unlock()
+		return nil, false
+	}
+	prev := rs.previous[0]
+	rs.previous = rs.previous[1:]
+	rs.mu.Unlock()
+
+	if prev.files || prev.channelID != channelID {
+		rs.r.deleteResponses(rs.Session, []response{prev})
+		return nil, false
+	}
+
+	// Set whichever of content and embed isn't empty first, so the message is
+	// never left empty in between.
+	var err error
+	if params.Content != "" {
+		msg, err = rs.Session.SetMsgContent(ctx, channelID, prev.messageID, params.Content)
+		if err == nil {
+			msg, err = rs.Session.SetMsgEmbed(ctx, channelID, prev.messageID, params.Embed)
+		}
+	} else {
+		msg, err = rs.Session.SetMsgEmbed(ctx, channelID, prev.messageID, params.Embed)
+		if err == nil {
+			msg, err = rs.Session.SetMsgContent(ctx, channelID, prev.messageID, "")
+		}
+	}
+	if err != nil {
+		rs.r.Log.Warn(ctx, "failed to edit previous response", slog.Error(err), slog.F("message_id", prev.messageID))
+		rs.r.deleteResponses(rs.Session, []response{prev})
+		return nil, false
+	}
+
+	rs.record(msg, false)
+	return msg, true
+}
+
+// handleCommandEdit re-runs a recent command when its message is edited,
+// editing the previous responses in place.
+func (r *Rikka) handleCommandEdit(s disgord.Session, mu *disgord.MessageUpdate) {
+	if mu.Message == nil {
+		return
+	}
+
+	// Discord also sends partial updates when it adds link previews. They
+	// have no content or edit timestamp, so they aren't edits by the author.
+	if mu.Message.EditedTimestamp.IsZero() || mu.Message.Content == "" {
+		return
+	}
+
+	r.responses.mu.Lock()
+	inv, ok := r.responses.byCommand[mu.Message.ID]
+	r.responses.mu.Unlock()
+	if !ok || time.Since(inv.at) > ResponseWindow || mu.Message.Content == inv.content {
+		return
+	}
+
+	msg := mu.Message
+	if msg.Author == nil {
+		msg.Author = inv.author
+	}
+
+	r.spawn(disgord.EvtMessageUpdate, s, mu, func(ctx context.Context) {
+		rs := r.trackResponses(s, msg)
+		defer rs.finish()
+
+		r.route(rs, &disgord.MessageCreate{Message: msg, Ctx: ctx, ShardID: mu.ShardID})
+	})
+}
+
+// handleCommandDelete deletes the responses to a recent command when its
+// message is deleted.
+func (r *Rikka) handleCommandDelete(s disgord.Session, md *disgord.MessageDelete) {
+	r.responses.mu.Lock()
+	inv, ok := r.responses.byCommand[md.MessageID]
+	delete(r.responses.byCommand, md.MessageID)
+	r.responses.mu.Unlock()
+	if !ok || time.Since(inv.at) > ResponseWindow {
+		return
+	}
+
+	r.deleteResponses(s, inv.sent)
+}
+
+func (r *Rikka) deleteResponses(s disgord.Session, sent []response) {
+	if len(sent) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, r.HandlerTimeout)
+	defer cancel()
+
+	for _, e := range sent {
+		err := s.DeleteMessage(ctx, e.channelID, e.messageID)
+		if err != nil {
+			r.Log.Debug(ctx, "failed to delete response", slog.Error(err), slog.F("message_id", e.messageID))
+		}
+	}
+}
+
+// editableParams converts the data passed to SendMsg to message params. ok is
+// false if the data can't be applied to an existing message, e.g. because it
+// includes files.
+func editableParams(data []interface{}) (params *disgord.CreateMessageParams, ok bool) {
+	params = &disgord.CreateMessageParams{}
+	for _, e := range data {
+		switch e := e.(type) {
+		case nil:
+		case *disgord.CreateMessageParams:
+			*params = *e
+		case disgord.CreateMessageParams:
+			*params = e
+		case *disgord.Embed:
+			params.Embed = e
+		case string:
+			if params.Content != "" {
+				return nil, false
+			}
+			params.Content = e
+		default:
+			return nil, false
+		}
+	}
+
+	return params, len(params.Files) == 0
+}
+
+func hasFiles(data []interface{}) bool {
+	for _, e := range data {
+		switch e := e.(type) {
+		case disgord.CreateMessageFileParams, *disgord.CreateMessageFileParams:
+			return true
+		case disgord.CreateMessageParams:
+			if len(e.Files) > 0 {
+				return true
+			}
+		case *disgord.CreateMessageParams:
+			if len(e.Files) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package rikka_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/rikkatest"
+)
+
+// echoCmd replies with its arguments, or an error if there are none.
+type echoCmd struct {
+	*rikka.Rikka
+}
+
+func (c *echoCmd) Register(fn func(event string, inputs ...interface{})) {}
+func (c *echoCmd) Help() []rikka.CommandHelp {
+	return []rikka.CommandHelp{{Name: "echo"}}
+}
+func (c *echoCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args rikka.Args) {
+	if len(args) == 0 {
+		c.HandleError(mc.Ctx, s, mc.Message, rikka.BadInput("Nothing to echo"), "Invalid input")
+		return
+	}
+
+	for _, e := range args.Strings() {
+		s.SendMsg(mc.Ctx, mc.Message.ChannelID, e)
+	}
+}
+
+func TestCommandEdit(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(&echoCmd{Rikka: h.Rikka})
+
+	msg := h.NewMessage("r.echo")
+	reply := h.ExpectReply(h.MessageCreate(msg), "Nothing to echo")
+
+	msg.Content = "r.echo hello"
+	h.ExpectNoReply(h.MessageUpdate(msg))
+	if reply.Content != "hello" || len(reply.Embeds) != 0 {
+		t.Fatalf("response was not edited: %q", reply.Text())
+	}
+
+	// Extra responses are sent as new messages.
+	msg.Content = "r.echo hello world"
+	extra := h.ExpectReply(h.MessageUpdate(msg), "world")
+	if reply.Content != "hello" || reply.Deleted {
+		t.Fatalf("first response changed: %q", reply.Text())
+	}
+
+	// Responses that are no longer needed are deleted.
+	msg.Content = "r.echo bye"
+	h.ExpectNoReply(h.MessageUpdate(msg))
+	if reply.Content != "bye" || !extra.Deleted {
+		t.Fatal("responses were not updated")
+	}
+
+	// Editing the message into something else deletes the responses.
+	msg.Content = "never mind"
+	h.MessageUpdate(msg)
+	if !reply.Deleted {
+		t.Fatal("response to a message that is no longer a command was not deleted")
+	}
+}
+
+func TestCommandEmbedUpdate(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(&echoCmd{Rikka: h.Rikka})
+
+	msg := h.NewMessage("r.echo see https://example.com")
+	replies := h.MessageCreate(msg)
+
+	// Link previews arrive as an update with only the embeds set.
+	h.ExpectNoReply(h.Dispatch(disgord.EvtMessageUpdate, &disgord.MessageUpdate{
+		Message: &disgord.Message{
+			ID:        msg.ID,
+			ChannelID: msg.ChannelID,
+			GuildID:   msg.GuildID,
+			Embeds:    []*disgord.Embed{{URL: "https://example.com"}},
+		},
+		Ctx: context.Background(),
+	}))
+	for _, e := range replies {
+		if e.Deleted {
+			t.Fatalf("response %q was deleted by a link preview", e.Content)
+		}
+	}
+}
+
+func TestCommandDelete(t *testing.T) {
+	h := rikkatest.New(t)
+	h.Register(&echoCmd{Rikka: h.Rikka})
+
+	msg := h.NewMessage("r.echo a b")
+	replies := h.MessageCreate(msg)
+	other := h.ExpectReply(h.Send("r.echo c"), "c")
+
+	h.MessageDelete(msg)
+	for _, e := range replies {
+		if !e.Deleted {
+			t.Fatalf("response %q was not deleted", e.Content)
+		}
+	}
+	if other.Deleted {
+		t.Fatal("response to another command was deleted")
+	}
+
+	// Edits of messages that aren't commands are ignored.
+	plain := h.NewMessage("hello")
+	h.MessageCreate(plain)
+	plain.Content = "r.echo hello"
+	h.ExpectNoReply(h.MessageUpdate(plain))
+}
@@ -3,80 +3,226 @@ package rikka
 import (
 	"context"
 	"os"
+	"sync"
+	"time"
 
 	"cdr.dev/slog"
 	"cdr.dev/slog/sloggers/sloghuman"
 	"github.com/andersfylling/disgord"
-	"github.com/apple/foundationdb/bindings/go/src/fdb"
+
+	"github.com/coadler/rikka2/storage"
 )
 
-func New(fdb fdb.Database, token string) *Rikka {
-	return &Rikka{
-		Log:    sloghuman.Make(os.Stdout),
-		ctx:    context.Background(),
-		fdb:    fdb,
-		token:  token,
-		Prefix: "r.",
-		Client: disgord.New(disgord.Config{
-			BotToken:           token,
-			LoadMembersQuietly: true,
-			// Logger:             disgord.DefaultLogger(false),
-		}),
+func New(store storage.Store, cfg Config) *Rikka {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Rikka{
+		Log:    sloghuman.Make(os.Stdout).Leveled(logLevels[cfg.LogLevel]),
+		ctx:    ctx,
+		cancel: cancel,
+		store:  store,
+		token:  cfg.Token,
+		Prefix: cfg.Prefix,
+		Owners: cfg.Owners,
+
+		ErrorChannel: cfg.ErrorChannel,
+		HTTPAddr:     cfg.HTTPAddr,
+
+		HandlerTimeout:   DefaultHandlerTimeout,
+		ShutdownTimeout:  DefaultShutdownTimeout,
+		PaginatorTimeout: DefaultPaginatorTimeout,
+		AuditRetention:   DefaultAuditRetention,
+	}
+	r.Client = disgord.New(disgord.Config{
+		BotToken:           cfg.Token,
+		LoadMembersQuietly: true,
+		Logger:             gatewayLogger{r: r},
+	})
+
+	dir, err := store.Directory("rikka", "prefix")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
+	}
+	r.prefixes = prefixes{
+		dir:   dir,
+		cache: map[disgord.Snowflake][]string{},
+		gens:  map[disgord.Snowflake]uint64{},
+	}
+
+	dir, err = store.Directory("rikka", "permissions")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
+	}
+	r.permissions = permissions{
+		dir:   dir,
+		cache: map[disgord.Snowflake][]PermissionOverride{},
+	}
+
+	dir, err = store.Directory("rikka", "modules")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
+	}
+	r.modules = modules{
+		dir:   dir,
+		cache: map[disgord.Snowflake]map[string]bool{},
+		gens:  map[disgord.Snowflake]uint64{},
+	}
+
+	dir, err = store.Directory("rikka", "suggestions")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
+	}
+	r.suggestions = suggestions{
+		dir:   dir,
+		cache: map[disgord.Snowflake]bool{},
+		gens:  map[disgord.Snowflake]uint64{},
+	}
+
+	dir, err = store.Directory("rikka", "audit")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
 	}
+	r.audit = auditLog{dir: dir}
+
+	dir, err = store.Directory("rikka", "scheduler")
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to create directory", slog.Error(err))
+	}
+	r.Scheduler = newScheduler(r, dir)
+	r.Scheduler.Handle(auditPurgeJob, func(ctx context.Context, _ disgord.Session, _ *Job, _ struct{}) error {
+		return r.purgeAudit(ctx)
+	})
+
+	r.addDefaultHealthChecks()
+
+	return r
 }
 
 type Rikka struct {
-	Log slog.Logger
-	ctx context.Context
-	fdb fdb.Database
+	Log    slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	store  storage.Store
 
 	token  string
 	Prefix string
+	Owners []disgord.Snowflake
+
+	// ErrorChannel receives full reports of recovered panics if set.
+	ErrorChannel disgord.Snowflake
+	// HTTPAddr is the address the metrics and health endpoints listen on.
+	// They are disabled if empty.
+	HTTPAddr string
+
+	HandlerTimeout  time.Duration
+	ShutdownTimeout time.Duration
+	// PaginatorTimeout is how long paginated messages respond to reactions
+	// after they were last used.
+	PaginatorTimeout time.Duration
+	// AuditRetention is how long commands are kept in the audit log. They
+	// are kept forever if it is zero.
+	AuditRetention time.Duration
+
+	// Scheduler runs jobs at a later time. Handlers must be registered
+	// before jobs for them are scheduled.
+	Scheduler *Scheduler
+
+	prefixes    prefixes
+	permissions permissions
+	modules     modules
+	suggestions suggestions
+	lifecycle   lifecycle
+	cooldowns   limiter
+	health      health
+	paginators  paginators
+	awaiters    awaiters
+	responses   responses
+	audit       auditLog
+
+	metricsMu     sync.Mutex
+	countedEvents map[string]bool
 
 	self *disgord.User
-	cmds []Command
+
+	// routerMu guards cmds and router, which are replaced when commands are
+	// registered.
+	routerMu sync.RWMutex
+	cmds     []Command
+	router   router
 
 	Client *disgord.Client
 }
 
+// IsOwner returns true if id belongs to one of the bot owners.
+func (r *Rikka) IsOwner(id disgord.Snowflake) bool {
+	return hasSnowflake(r.Owners, id)
+}
+
 func (r *Rikka) RegisterCommands(cmds ...Command) {
 	r.Log.Info(r.ctx, "registering commands", slog.F("count", len(cmds)))
 
+	cmds = append([]Command{&helpCmd{Rikka: r}}, cmds...)
+
+	router, err := newRouter(cmds)
+	if err != nil {
+		r.Log.Fatal(r.ctx, "failed to register commands", slog.Error(err))
+	}
+
+	r.routerMu.Lock()
 	r.cmds = cmds
+	r.router = router
+	r.routerMu.Unlock()
 	for _, e := range cmds {
-		e.Register(r.Client.On)
+		e.Register(r.moduleOn(commandModule(e)))
 	}
 
-	r.Client.On("MESSAGE_CREATE", r.registerHelp)
+	r.On("MESSAGE_CREATE", r.dispatch)
+	r.On("MESSAGE_CREATE", r.handleAwaitedMessage)
+	r.On("MESSAGE_UPDATE", r.handleCommandEdit)
+	r.On("MESSAGE_DELETE", r.handleCommandDelete)
+	r.On("MESSAGE_REACTION_ADD", r.handlePageReaction)
+	r.On("MESSAGE_REACTION_ADD", r.handleAwaitedReaction)
 }
 
-func (r *Rikka) Open() {
-	defer r.Client.StayConnectedUntilInterrupted(r.ctx)
-
-	r.Client.On("READY", func(s disgord.Session, h *disgord.Ready) {
-		r.Log.Info(h.Ctx, "ready")
-	})
+// registered returns the registered commands and the router built from them.
+func (r *Rikka) registered() ([]Command, router) {
+	r.routerMu.RLock()
+	defer r.routerMu.RUnlock()
 
-	self, err := r.Client.Myself(r.ctx)
-	if err != nil {
-		r.Log.Error(r.ctx, "failed to get self", slog.Error(err))
-	} else {
-		r.self = self
-	}
+	return r.cmds, r.router
 }
 
-func (r *Rikka) Transact(fn func(t fdb.Transaction) error) error {
-	_, err := r.fdb.Transact(func(t fdb.Transaction) (interface{}, error) {
-		return nil, fn(t)
+// Transact runs fn in a transaction against the bot's store.
+func (r *Rikka) Transact(fn func(t storage.Transaction) error) error {
+	var (
+		start    = time.Now()
+		attempts int
+	)
+	err := r.store.Transact(func(t storage.Transaction) error {
+		attempts++
+		return fn(t)
 	})
+	observeTransaction("write", start, attempts)
 
 	return err
 }
 
-func (r *Rikka) ReadTransact(fn func(t fdb.ReadTransaction) error) error {
-	_, err := r.fdb.ReadTransact(func(t fdb.ReadTransaction) (interface{}, error) {
-		return nil, fn(t)
+// ReadTransact runs fn in a read only transaction against the bot's store.
+func (r *Rikka) ReadTransact(fn func(t storage.ReadTransaction) error) error {
+	var (
+		start    = time.Now()
+		attempts int
+	)
+	err := r.store.ReadTransact(func(t storage.ReadTransaction) error {
+		attempts++
+		return fn(t)
 	})
+	observeTransaction("read", start, attempts)
 
 	return err
 }
+
+// Directory returns the subspace of the store for path, creating it if
+// needed.
+func (r *Rikka) Directory(path ...string) (storage.Subspace, error) {
+	return r.store.Directory(path...)
+}
@@ -0,0 +1,209 @@
+// Package rikkatest runs commands against a fake Discord session, so they can
+// be tested without connecting to Discord.
+//
+//	h := rikkatest.New(t)
+//	h.Register(commands.NewPingCommand(h.Rikka))
+//	h.ExpectReply(h.Send("r.ping"), "Pong!")
+package rikkatest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cdr.dev/slog/sloggers/slogtest"
+	"github.com/andersfylling/disgord"
+
+	rikka "github.com/coadler/rikka2"
+	"github.com/coadler/rikka2/fake"
+	"github.com/coadler/rikka2/storage"
+)
+
+// Harness is a bot backed by a fake session and an in-memory store. Events
+// are injected through the same handlers the bot registers with Discord.
+type Harness struct {
+	T       testing.TB
+	Rikka   *rikka.Rikka
+	Session *fake.Session
+	Store   *storage.Memory
+
+	// Guild, Channel and User are where messages sent with Send come from.
+	// User is a member of Guild without any roles.
+	Guild   *disgord.Guild
+	Channel *disgord.Channel
+	User    *disgord.User
+	// Owner owns Guild.
+	Owner *disgord.User
+}
+
+// New returns a Harness with a guild containing a single channel, its owner
+// and one other member. The bot uses the default config with the prefix r.
+func New(t testing.TB) *Harness {
+	cfg := rikka.DefaultConfig()
+	cfg.Token = "test"
+
+	store := storage.NewMemory()
+	r := rikka.New(store, cfg)
+	r.Log = slogtest.Make(t, &slogtest.Options{IgnoreErrors: true})
+
+	s := fake.NewSession(&disgord.User{ID: 1, Username: "rikka", Bot: true})
+
+	var (
+		owner = &disgord.User{ID: 2, Username: "owner", Discriminator: 1}
+		user  = &disgord.User{ID: 3, Username: "user", Discriminator: 2}
+		guild = &disgord.Guild{
+			ID:      10,
+			Name:    "Test Guild",
+			OwnerID: owner.ID,
+			Roles:   []*disgord.Role{{ID: 10, Name: "@everyone", Permissions: disgord.PermissionSendMessages}},
+		}
+		channel = &disgord.Channel{ID: 20, GuildID: guild.ID, Name: "general", Type: disgord.ChannelTypeGuildText}
+	)
+	guild.Channels = []*disgord.Channel{channel}
+	s.AddGuild(guild)
+	s.AddMember(guild.ID, &disgord.Member{User: owner})
+	s.AddMember(guild.ID, &disgord.Member{User: user})
+
+	return &Harness{
+		T:       t,
+		Rikka:   r,
+		Session: s,
+		Store:   store,
+		Guild:   guild,
+		Channel: channel,
+		User:    user,
+		Owner:   owner,
+	}
+}
+
+// Register registers commands with the bot the same way the bot binary does.
+func (h *Harness) Register(cmds ...rikka.Command) {
+	h.Rikka.RegisterCommands(cmds...)
+}
+
+// NewMessage returns a message from User in Channel, which has not been sent.
+func (h *Harness) NewMessage(content string) *disgord.Message {
+	return &disgord.Message{
+		ID:        h.Session.NewID(),
+		ChannelID: h.Channel.ID,
+		GuildID:   h.Guild.ID,
+		Author:    h.User,
+		Content:   content,
+		Timestamp: disgord.Time{Time: time.Now()},
+	}
+}
+
+// Send sends a message from User in Channel and returns the replies.
+func (h *Harness) Send(content string) Replies {
+	return h.MessageCreate(h.NewMessage(content))
+}
+
+// SendAs sends a message from user in Channel and returns the replies.
+func (h *Harness) SendAs(user *disgord.User, content string) Replies {
+	msg := h.NewMessage(content)
+	msg.Author = user
+	return h.MessageCreate(msg)
+}
+
+// MessageCreate dispatches a MESSAGE_CREATE event for msg and returns the
+// messages sent while handling it.
+func (h *Harness) MessageCreate(msg *disgord.Message) Replies {
+	h.Session.AddMessage(msg)
+	return h.dispatch(disgord.EvtMessageCreate, &disgord.MessageCreate{Message: msg, Ctx: context.Background()})
+}
+
+// MessageUpdate dispatches a MESSAGE_UPDATE event for msg and returns the
+// messages sent while handling it.
+func (h *Harness) MessageUpdate(msg *disgord.Message) Replies {
+	msg.EditedTimestamp = disgord.Time{Time: time.Now()}
+	h.Session.AddMessage(msg)
+	return h.dispatch(disgord.EvtMessageUpdate, &disgord.MessageUpdate{Message: msg, Ctx: context.Background()})
+}
+
+// MessageDelete dispatches a MESSAGE_DELETE event for msg and returns the
+// messages sent while handling it.
+func (h *Harness) MessageDelete(msg *disgord.Message) Replies {
+	return h.dispatch(disgord.EvtMessageDelete, &disgord.MessageDelete{
+		MessageID: msg.ID,
+		ChannelID: msg.ChannelID,
+		GuildID:   msg.GuildID,
+		Ctx:       context.Background(),
+	})
+}
+
+// React adds a reaction from user to msg, dispatches a MESSAGE_REACTION_ADD
+// event for it and returns the messages sent while handling it.
+func (h *Harness) React(user *disgord.User, msg *disgord.Message, emoji string) Replies {
+	h.T.Helper()
+
+	err := h.Session.AddReaction(msg.ChannelID, msg.ID, user.ID, emoji)
+	if err != nil {
+		h.T.Fatalf("failed to react to message: %v", err)
+	}
+
+	return h.dispatch(disgord.EvtMessageReactionAdd, &disgord.MessageReactionAdd{
+		UserID:       user.ID,
+		ChannelID:    msg.ChannelID,
+		MessageID:    msg.ID,
+		PartialEmoji: &disgord.Emoji{Name: emoji},
+		Ctx:          context.Background(),
+	})
+}
+
+// Dispatch dispatches any event and returns the messages sent while handling
+// it.
+func (h *Harness) Dispatch(event string, evt interface{}) Replies {
+	return h.dispatch(event, evt)
+}
+
+func (h *Harness) dispatch(event string, evt interface{}) Replies {
+	before := len(h.Session.Sent())
+	h.Rikka.DispatchEvent(h.Session, event, evt)
+	return Replies(h.Session.Sent()[before:])
+}
+
+// Replies are the messages sent while handling an event.
+type Replies []*fake.Message
+
+// Text returns the text of every reply.
+func (r Replies) Text() string {
+	texts := make([]string, len(r))
+	for i, e := range r {
+		texts[i] = e.Text()
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// Find returns the first reply whose text contains substr.
+func (r Replies) Find(substr string) (*fake.Message, bool) {
+	for _, e := range r {
+		if strings.Contains(e.Text(), substr) {
+			return e, true
+		}
+	}
+
+	return nil, false
+}
+
+// ExpectReply fails the test unless a reply contains substr, and returns it.
+func (h *Harness) ExpectReply(replies Replies, substr string) *fake.Message {
+	h.T.Helper()
+
+	m, ok := replies.Find(substr)
+	if !ok {
+		h.T.Fatalf("expected a reply containing %q, got %d replies:\n%s", substr, len(replies), replies.Text())
+	}
+
+	return m
+}
+
+// ExpectNoReply fails the test if anything was sent.
+func (h *Harness) ExpectNoReply(replies Replies) {
+	h.T.Helper()
+
+	if len(replies) > 0 {
+		h.T.Fatalf("expected no replies, got %d:\n%s", len(replies), replies.Text())
+	}
+}
@@ -0,0 +1,155 @@
+package rikka
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+)
+
+// CommandMiddlewarer is implemented by commands that need to filter messages
+// before they are handled, e.g. to restrict a command to the bot owner.
+type CommandMiddlewarer interface {
+	Middlewares() []Middleware
+}
+
+// route is a command along with the help entry it was registered under and
+// the module it belongs to.
+type route struct {
+	cmd    Command
+	help   CommandHelp
+	module string
+}
+
+// router maps lowercased command names and aliases to the command that
+// handles them.
+type router map[string]route
+
+// newRouter builds a lookup table from the names and aliases advertised by
+// each command's help. Every name and alias must be unique across all
+// commands.
+func newRouter(cmds []Command) (router, error) {
+	var (
+		rt     = router{}
+		owners = map[string]string{}
+	)
+
+	for _, cmd := range cmds {
+		for _, h := range cmd.Help() {
+			for _, e := range h.Cooldowns {
+				if e.Burst <= 0 || e.Per <= 0 {
+					return nil, xerrors.Errorf("cooldown %q of command %q needs a positive burst and period", e, h.Name)
+				}
+			}
+
+			for _, name := range append([]string{h.Name}, h.Aliases...) {
+				key := strings.ToLower(name)
+				if owner, ok := owners[key]; ok {
+					return nil, xerrors.Errorf("%q of command %q is already registered by command %q", name, h.Name, owner)
+				}
+
+				owners[key] = h.Name
+				rt[key] = route{cmd: cmd, help: h, module: commandModule(cmd)}
+			}
+		}
+	}
+
+	return rt, nil
+}
+
+// LookupCommand returns the help entry of the command registered under name,
+// which may also be an alias.
+func (r *Rikka) LookupCommand(name string) (CommandHelp, bool) {
+	_, rtr := r.registered()
+	rt, ok := rtr[strings.ToLower(name)]
+	return rt.help, ok
+}
+
+// dispatch handles messages that start with a prefix, tracking the responses
+// to them so the command can be re-run if the message is edited. Every
+// command shares this registration, so the command runs in its own goroutine
+// and slow ones don't hold up the rest.
+func (r *Rikka) dispatch(s disgord.Session, mc *disgord.MessageCreate) {
+	if _, ok := r.stripPrefix(mc.Message.GuildID, mc.Message.Content); !ok {
+		return
+	}
+
+	r.spawn(disgord.EvtMessageCreate, s, mc, func(ctx context.Context) {
+		rs := r.trackResponses(s, mc.Message)
+		defer rs.finish()
+
+		// Other registrations set the Ctx of mc, so the command gets its own
+		// event.
+		r.route(rs, &disgord.MessageCreate{Message: mc.Message, Ctx: ctx, ShardID: mc.ShardID})
+	})
+}
+
+// route routes a message to the single command matching its name or alias.
+// Commands in modules disabled in the message's guild are ignored, and
+// unknown commands are answered with the closest names if there are any.
+func (r *Rikka) route(s disgord.Session, mc *disgord.MessageCreate) {
+	name, args, ok := r.splitCommand(mc.Message.GuildID, mc.Message.Content)
+	if !ok {
+		return
+	}
+
+	_, rtr := r.registered()
+	rt, ok := rtr[name]
+	if !ok {
+		r.suggestCommand(s, mc, name)
+		return
+	}
+	if !r.ModuleEnabled(mc.Message.GuildID, rt.module) {
+		return
+	}
+	mc.Ctx = withCommand(mc.Ctx, rt.help.Name)
+
+	var audit *auditInvocation
+	mc.Ctx, audit = startAudit(mc, rt.help, args)
+	defer r.finishAudit(audit)
+
+	if m, ok := rt.cmd.(CommandMiddlewarer); ok {
+		e := NewEvent(s, mc)
+		for _, mw := range m.Middlewares() {
+			if !mw(e) {
+				return
+			}
+		}
+	}
+
+	allowed, reason, err := r.canRun(mc.Ctx, s, mc.Message, rt.help)
+	if err != nil {
+		r.HandleError(mc.Ctx, s, mc.Message, err, "Failed to check permissions")
+		return
+	}
+	if !allowed {
+		r.HandleError(mc.Ctx, s, mc.Message, PermissionDenied(reason), "Permission denied")
+		return
+	}
+
+	if !r.checkCooldowns(s, mc, rt.help) {
+		return
+	}
+
+	defer observeCommand(rt.help.Name, time.Now())
+	audit.handle(func() { rt.cmd.Handle(s, mc, args) })
+}
+
+// splitCommand strips the prefix from a message and returns the lowercased
+// command name and its arguments. ok is false if the message does not start
+// with a prefix or contains no command name.
+func (r *Rikka) splitCommand(guildID disgord.Snowflake, message string) (name string, args Args, ok bool) {
+	message, ok = r.stripPrefix(guildID, message)
+	if !ok {
+		return "", nil, false
+	}
+
+	args = Tokenize(message)
+	if len(args) == 0 {
+		return "", nil, false
+	}
+
+	return strings.ToLower(args.Pop()), args, true
+}
@@ -0,0 +1,103 @@
+package rikka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+)
+
+type testCmd struct {
+	help CommandHelp
+}
+
+func (c *testCmd) Register(fn func(event string, inputs ...interface{}))          {}
+func (c *testCmd) Help() []CommandHelp                                            { return []CommandHelp{c.help} }
+func (c *testCmd) Handle(s disgord.Session, mc *disgord.MessageCreate, args Args) {}
+
+func TestNewRouter(t *testing.T) {
+	seen := &testCmd{help: CommandHelp{Name: "seen", Aliases: []string{"lastseen", "LastActive"}}}
+	ping := &testCmd{help: CommandHelp{Name: "ping"}}
+
+	rt, err := newRouter([]Command{seen, ping})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, want := range map[string]Command{
+		"seen":       seen,
+		"lastseen":   seen,
+		"lastactive": seen,
+		"ping":       ping,
+	} {
+		if got := rt[name].cmd; got != want {
+			t.Errorf("rt[%q] = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNewRouterDuplicate(t *testing.T) {
+	_, err := newRouter([]Command{
+		&testCmd{help: CommandHelp{Name: "seen", Aliases: []string{"ls"}}},
+		&testCmd{help: CommandHelp{Name: "LS"}},
+	})
+	if err == nil {
+		t.Fatal("expected duplicate alias error")
+	}
+}
+
+func TestNewRouterCooldowns(t *testing.T) {
+	for _, c := range []Cooldown{
+		{Burst: 0, Per: time.Second},
+		{Burst: -1, Per: time.Second},
+		{Burst: 1, Per: 0},
+		{Burst: 1, Per: -time.Second},
+	} {
+		_, err := newRouter([]Command{&testCmd{help: CommandHelp{Name: "ping", Cooldowns: []Cooldown{c}}}})
+		if err == nil {
+			t.Errorf("expected an error for cooldown %s", c)
+		}
+	}
+
+	_, err := newRouter([]Command{&testCmd{help: CommandHelp{Name: "ping", Cooldowns: []Cooldown{{Burst: 1, Per: time.Second}}}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	bot := &Rikka{
+		Prefix: "r.",
+		self:   &disgord.User{ID: 1234},
+	}
+
+	for _, tc := range []struct {
+		message string
+		name    string
+		args    []string
+		ok      bool
+	}{
+		{"r.ping", "ping", nil, true},
+		{"  R.Seen 1234  5678 ", "seen", []string{"1234", "5678"}, true},
+		{"r. stats", "stats", nil, true},
+		{"ping", "", nil, false},
+		{"r.", "", nil, false},
+		{"r", "", nil, false},
+		{"<@1234> help seen", "help", []string{"seen"}, true},
+		{"<@!1234>ping", "ping", nil, true},
+		{"<@5678> ping", "", nil, false},
+	} {
+		name, args, ok := bot.splitCommand(0, tc.message)
+		if name != tc.name || ok != tc.ok || len(args) != len(tc.args) {
+			t.Errorf("splitCommand(%q) = %q, %q, %v", tc.message, name, args, ok)
+			continue
+		}
+
+		for i := range args {
+			if args[i].Value != tc.args[i] {
+				t.Errorf("splitCommand(%q) args = %q, want %q", tc.message, args, tc.args)
+				break
+			}
+		}
+	}
+}
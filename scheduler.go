@@ -0,0 +1,731 @@
+package rikka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+const (
+	// DefaultPollInterval is how often the scheduler checks for due jobs.
+	DefaultPollInterval = time.Second
+	// DefaultJobTimeout is how long a single job may run before its context
+	// is cancelled.
+	DefaultJobTimeout = time.Minute
+	// DefaultMaxAttempts is how many times a failing job is tried.
+	DefaultMaxAttempts = 5
+	// DefaultRetryBackoff is how long the scheduler waits before retrying a
+	// job the first time. The wait doubles with every attempt.
+	DefaultRetryBackoff = 10 * time.Second
+	// DefaultMaxRetryBackoff is the longest the scheduler waits before
+	// retrying a job.
+	DefaultMaxRetryBackoff = time.Hour
+	// DefaultMaxConcurrentJobs is how many jobs a scheduler runs at once.
+	DefaultMaxConcurrentJobs = 16
+)
+
+// schedulerBatch is the maximum amount of jobs claimed at once.
+const schedulerBatch = 16
+
+// ErrJobNotFound is returned when cancelling a job that doesn't exist, e.g.
+// because it already ran.
+var ErrJobNotFound = xerrors.New("job not found")
+
+// Scheduler runs jobs at a later time. Jobs are kept in the store ordered by
+// the time they are due, so they survive restarts, and are run by the handler
+// registered with the name they were scheduled for.
+//
+// Every instance of the bot sharing a store runs a scheduler. Jobs are
+// claimed in a transaction before they run, so a job is run by at most one
+// instance. A job whose instance stops while running it is never run again:
+// one-shot jobs are dropped and recurring jobs skip to their next occurrence.
+type Scheduler struct {
+	// PollInterval is how often the store is checked for due jobs.
+	PollInterval time.Duration
+	// JobTimeout is how long a job may run. An instance that hasn't finished
+	// a job after twice as long is assumed to have stopped.
+	JobTimeout time.Duration
+	// MaxAttempts is how many times a failing job is tried. Recurring jobs
+	// that fail this many times skip to their next occurrence.
+	MaxAttempts int
+	// RetryBackoff and MaxRetryBackoff bound the exponential wait between
+	// attempts of a failing job.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// MaxConcurrentJobs is how many jobs this instance runs at once. Due jobs
+	// are left for other instances or a later poll while it is busy.
+	MaxConcurrentJobs int
+
+	r   *Rikka
+	dir storage.Subspace
+	// instance identifies this scheduler in leases and logs.
+	instance string
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]jobHandler
+
+	// active is the amount of jobs running, and running tracks them so they
+	// can be waited for.
+	activeMu sync.Mutex
+	active   int
+	running  sync.WaitGroup
+}
+
+// Job is a scheduled job, as passed to its handler.
+type Job struct {
+	ID      string
+	Handler string
+	// Due is when the job was due to run.
+	Due time.Time
+	// Cron is the schedule of a recurring job, or empty for one-shot jobs.
+	Cron string
+	// Attempt is the number of earlier attempts that failed.
+	Attempt int
+}
+
+// jobHandler is a func registered with Handle.
+type jobHandler struct {
+	fn      reflect.Value
+	payload reflect.Type
+}
+
+// jobRecord is a job as stored.
+type jobRecord struct {
+	Handler string
+	Payload json.RawMessage
+	Due     time.Time
+	Cron    string `json:",omitempty"`
+	Attempt int
+	// Lease is set while an instance is running the job.
+	Lease *jobLease `json:",omitempty"`
+}
+
+type jobLease struct {
+	Owner string
+	Token string
+	Until time.Time
+}
+
+var (
+	jobType     = reflect.TypeOf((*Job)(nil))
+	sessionType = reflect.TypeOf((*disgord.Session)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func newScheduler(r *Rikka, dir storage.Subspace) *Scheduler {
+	return &Scheduler{
+		PollInterval:    DefaultPollInterval,
+		JobTimeout:      DefaultJobTimeout,
+		MaxAttempts:     DefaultMaxAttempts,
+		RetryBackoff:    DefaultRetryBackoff,
+		MaxRetryBackoff: DefaultMaxRetryBackoff,
+
+		MaxConcurrentJobs: DefaultMaxConcurrentJobs,
+
+		r:        r,
+		dir:      dir,
+		instance: newJobID(),
+		now:      time.Now,
+		handlers: map[string]jobHandler{},
+	}
+}
+
+// Handle registers the handler run for jobs scheduled with name. fn must be
+// of the form
+//
+//	func(ctx context.Context, s disgord.Session, job *rikka.Job, payload T) error
+//
+// where T is the type of the payload the jobs are scheduled with. Payloads
+// are stored as JSON. Returning an error retries the job with backoff.
+func (s *Scheduler) Handle(name string, fn interface{}) {
+	v := reflect.ValueOf(fn)
+	typ := v.Type()
+	if typ.Kind() != reflect.Func || typ.NumIn() != 4 || typ.NumOut() != 1 ||
+		typ.In(0) != contextType || typ.In(1) != sessionType || typ.In(2) != jobType || typ.Out(0) != errorType {
+		s.r.Log.Fatal(s.r.ctx, "invalid job handler", slog.F("handler", name), slog.F("type", typ.String()))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.handlers[name]; ok {
+		s.r.Log.Fatal(s.r.ctx, "duplicate job handler", slog.F("handler", name))
+	}
+	s.handlers[name] = jobHandler{fn: v, payload: typ.In(3)}
+}
+
+// Schedule stores a job that runs the handler registered with name at due,
+// and returns its id. payload must be assignable to the handler's payload
+// type.
+func (s *Scheduler) Schedule(name string, due time.Time, payload interface{}) (string, error) {
+	return s.schedule(name, due, "", payload)
+}
+
+// ScheduleRecurring stores a job that runs the handler registered with name
+// every time the cron expression spec matches, and returns its id. See
+// ParseCron for the syntax.
+func (s *Scheduler) ScheduleRecurring(name string, spec string, payload interface{}) (string, error) {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return "", err
+	}
+
+	due := c.Next(s.now())
+	if due.IsZero() {
+		return "", xerrors.Errorf("cron expression %q never matches", spec)
+	}
+
+	return s.schedule(name, due, spec, payload)
+}
+
+// EnsureRecurring schedules a single recurring job for the handler registered
+// with name, using name as its id. Unlike ScheduleRecurring it can be called
+// every time the bot starts: the job is only replaced if it doesn't exist or
+// its schedule changed, so its next run isn't pushed back.
+func (s *Scheduler) EnsureRecurring(name string, spec string, payload interface{}) error {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	due := c.Next(s.now())
+	if due.IsZero() {
+		return xerrors.Errorf("cron expression %q never matches", spec)
+	}
+
+	rec, err := s.newRecord(name, due, spec, payload)
+	if err != nil {
+		return err
+	}
+
+	err = s.r.Transact(func(t storage.Transaction) error {
+		old, err := s.getJob(t, name)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			if old.Cron == spec && old.Handler == name {
+				return nil
+			}
+			s.deleteJob(t, name, old)
+		}
+
+		return s.putJob(t, name, rec)
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact job: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) schedule(name string, due time.Time, spec string, payload interface{}) (string, error) {
+	rec, err := s.newRecord(name, due, spec, payload)
+	if err != nil {
+		return "", err
+	}
+
+	id := newJobID()
+	err = s.r.Transact(func(t storage.Transaction) error {
+		return s.putJob(t, id, rec)
+	})
+	if err != nil {
+		return "", xerrors.Errorf("failed to transact job: %w", err)
+	}
+
+	return id, nil
+}
+
+// newRecord returns the record of a job running the handler registered with
+// name.
+func (s *Scheduler) newRecord(name string, due time.Time, spec string, payload interface{}) (jobRecord, error) {
+	s.mu.RLock()
+	h, ok := s.handlers[name]
+	s.mu.RUnlock()
+	if !ok {
+		return jobRecord{}, xerrors.Errorf("no job handler named %q", name)
+	}
+	if payload != nil && !reflect.TypeOf(payload).AssignableTo(h.payload) {
+		return jobRecord{}, xerrors.Errorf("job handler %q takes a %s payload, not %T", name, h.payload, payload)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return jobRecord{}, xerrors.Errorf("failed to encode payload: %w", err)
+	}
+
+	return jobRecord{Handler: name, Payload: raw, Due: due.UTC(), Cron: spec}, nil
+}
+
+// Cancel deletes a job so it doesn't run again. A job that is running when it
+// is cancelled finishes its current run.
+func (s *Scheduler) Cancel(id string) error {
+	err := s.r.Transact(func(t storage.Transaction) error {
+		rec, err := s.getJob(t, id)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return ErrJobNotFound
+		}
+
+		s.deleteJob(t, id, rec)
+		return nil
+	})
+	if err != nil && !xerrors.Is(err, ErrJobNotFound) {
+		return xerrors.Errorf("failed to transact job: %w", err)
+	}
+
+	return err
+}
+
+// Run runs due jobs with sess as their session until ctx is done.
+func (s *Scheduler) Run(ctx context.Context, sess disgord.Session) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.RunDue(ctx, sess)
+		if err != nil {
+			s.r.Log.Error(ctx, "failed to run due jobs", slog.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunDue claims the jobs that are due and starts running them, without
+// waiting for them to finish. No more than MaxConcurrentJobs run at once, so
+// jobs that are due while the scheduler is busy are claimed by a later call.
+// It returns the amount of jobs started.
+func (s *Scheduler) RunDue(ctx context.Context, sess disgord.Session) (int, error) {
+	if !s.r.acquire() {
+		return 0, nil
+	}
+	defer s.r.lifecycle.inflight.Done()
+
+	err := s.recoverExpired()
+	if err != nil {
+		return 0, err
+	}
+
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+
+	free := s.MaxConcurrentJobs - s.active
+	if free <= 0 {
+		return 0, nil
+	}
+	if free > schedulerBatch {
+		free = schedulerBatch
+	}
+
+	jobs, err := s.claim(free)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range jobs {
+		s.active++
+		s.running.Add(1)
+		// Shutdown waits for the job like any other handler. RunDue is
+		// in-flight itself, so this can't race with shutdown starting.
+		s.r.lifecycle.inflight.Add(1)
+
+		go func(j claimedJob) {
+			defer func() {
+				s.activeMu.Lock()
+				s.active--
+				s.activeMu.Unlock()
+				s.running.Done()
+				s.r.lifecycle.inflight.Done()
+			}()
+
+			s.run(ctx, sess, j)
+		}(e)
+	}
+
+	return len(jobs), nil
+}
+
+// wait blocks until the jobs started by RunDue have finished.
+func (s *Scheduler) wait() {
+	s.running.Wait()
+}
+
+// claimedJob is a job leased to this scheduler.
+type claimedJob struct {
+	id  string
+	rec jobRecord
+}
+
+// claim leases up to limit jobs that are due. The due part of the queue is read
+// without snapshot isolation, so two instances claiming the same job conflict
+// and only one of them commits.
+func (s *Scheduler) claim(limit int) ([]claimedJob, error) {
+	var claimed []claimedJob
+	err := s.r.Transact(func(t storage.Transaction) error {
+		claimed = nil
+		now := s.now().UTC()
+
+		kvs, err := t.GetRange(storage.Range{
+			Begin: s.dir.Sub("queue").Range().Begin,
+			End:   s.dir.Pack(tuple.Tuple{"queue", now.UnixNano() + 1}),
+		}, storage.RangeOptions{Limit: limit})
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range kvs {
+			t.Clear(kv.Key)
+
+			id, err := s.unpackID(kv.Key)
+			if err != nil {
+				return err
+			}
+
+			rec, err := s.getJob(t, id)
+			if err != nil {
+				return err
+			}
+			if rec == nil {
+				continue
+			}
+
+			rec.Lease = &jobLease{Owner: s.instance, Token: newJobID(), Until: now.Add(2 * s.JobTimeout)}
+			err = s.setJob(t, id, *rec)
+			if err != nil {
+				return err
+			}
+			t.Set(s.fmtRunningKey(rec.Lease.Until, id), []byte{})
+
+			claimed = append(claimed, claimedJob{id: id, rec: *rec})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to transact job claims: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// recoverExpired releases the jobs whose lease has expired without being
+// finished. They aren't retried, since they may have run.
+func (s *Scheduler) recoverExpired() error {
+	type expired struct {
+		id  string
+		rec jobRecord
+	}
+
+	var dropped []expired
+	err := s.r.Transact(func(t storage.Transaction) error {
+		dropped = nil
+		now := s.now().UTC()
+
+		kvs, err := t.GetRange(storage.Range{
+			Begin: s.dir.Sub("running").Range().Begin,
+			End:   s.dir.Pack(tuple.Tuple{"running", now.UnixNano() + 1}),
+		}, storage.RangeOptions{Limit: schedulerBatch})
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range kvs {
+			t.Clear(kv.Key)
+
+			id, err := s.unpackID(kv.Key)
+			if err != nil {
+				return err
+			}
+
+			rec, err := s.getJob(t, id)
+			if err != nil {
+				return err
+			}
+			if rec == nil || rec.Lease == nil || rec.Lease.Until.After(now) {
+				continue
+			}
+
+			dropped = append(dropped, expired{id: id, rec: *rec})
+			rec.Lease = nil
+			rec.Attempt = 0
+			err = s.reschedule(t, id, rec, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact expired jobs: %w", err)
+	}
+
+	for _, e := range dropped {
+		s.r.Log.Warn(s.r.ctx, "job lease expired without finishing, not running it again",
+			slog.F("job_id", e.id),
+			slog.F("handler", e.rec.Handler),
+			slog.F("owner", e.rec.Lease.Owner),
+		)
+	}
+
+	return nil
+}
+
+// run runs a claimed job and records the outcome.
+func (s *Scheduler) run(ctx context.Context, sess disgord.Session, j claimedJob) {
+	start := time.Now()
+	err := s.call(ctx, sess, j)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		s.r.Log.Warn(ctx, "job failed",
+			slog.Error(err),
+			slog.F("job_id", j.id),
+			slog.F("handler", j.rec.Handler),
+			slog.F("attempt", j.rec.Attempt+1),
+		)
+	}
+	observeJob(j.rec.Handler, outcome, start)
+
+	err = s.finish(j, err)
+	if err != nil {
+		s.r.Log.Error(ctx, "failed to finish job", slog.Error(err), slog.F("job_id", j.id), slog.F("handler", j.rec.Handler))
+	}
+}
+
+// call runs the handler of j, recovering any panic it causes.
+func (s *Scheduler) call(ctx context.Context, sess disgord.Session, j claimedJob) (err error) {
+	s.mu.RLock()
+	h, ok := s.handlers[j.rec.Handler]
+	s.mu.RUnlock()
+	if !ok {
+		return xerrors.Errorf("no job handler named %q", j.rec.Handler)
+	}
+
+	payload := reflect.New(h.payload)
+	err = json.Unmarshal(j.rec.Payload, payload.Interface())
+	if err != nil {
+		return xerrors.Errorf("failed to decode payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.JobTimeout)
+	defer cancel()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.r.Log.Critical(ctx, "recovered panic in job handler",
+				slog.F("job_id", j.id),
+				slog.F("handler", j.rec.Handler),
+				slog.F("panic", fmt.Sprint(rec)),
+				slog.F("stack", string(debug.Stack())),
+			)
+			err = xerrors.Errorf("job handler panicked: %v", rec)
+		}
+	}()
+
+	sv := reflect.Zero(sessionType)
+	if sess != nil {
+		sv = reflect.ValueOf(sess)
+	}
+
+	job := &Job{
+		ID:      j.id,
+		Handler: j.rec.Handler,
+		Due:     j.rec.Due,
+		Cron:    j.rec.Cron,
+		Attempt: j.rec.Attempt,
+	}
+	out := h.fn.Call([]reflect.Value{reflect.ValueOf(ctx), sv, reflect.ValueOf(job), payload.Elem()})
+	err, _ = out[0].Interface().(error)
+
+	return err
+}
+
+// finish releases the lease on j and schedules its next run: the next
+// occurrence of a recurring job, or a retry if it failed.
+func (s *Scheduler) finish(j claimedJob, runErr error) error {
+	var dropped bool
+	err := s.r.Transact(func(t storage.Transaction) error {
+		dropped = false
+		t.Clear(s.fmtRunningKey(j.rec.Lease.Until, j.id))
+
+		rec, err := s.getJob(t, j.id)
+		if err != nil {
+			return err
+		}
+		// The job was cancelled, or its lease expired and it was released.
+		if rec == nil || rec.Lease == nil || rec.Lease.Token != j.rec.Lease.Token {
+			return nil
+		}
+		rec.Lease = nil
+
+		now := s.now().UTC()
+		if runErr != nil {
+			rec.Attempt++
+			if rec.Attempt < s.MaxAttempts {
+				rec.Due = now.Add(s.backoff(rec.Attempt))
+				return s.putJob(t, j.id, *rec)
+			}
+			dropped = rec.Cron == ""
+		}
+
+		rec.Attempt = 0
+		return s.reschedule(t, j.id, rec, now)
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact job: %w", err)
+	}
+
+	if dropped {
+		s.r.Log.Error(s.r.ctx, "job failed too many times, dropping it",
+			slog.F("job_id", j.id),
+			slog.F("handler", j.rec.Handler),
+			slog.F("attempts", s.MaxAttempts),
+		)
+	}
+
+	return nil
+}
+
+// reschedule queues a recurring job for its next occurrence after now, and
+// deletes one-shot jobs.
+func (s *Scheduler) reschedule(t storage.Transaction, id string, rec *jobRecord, now time.Time) error {
+	if rec.Cron == "" {
+		s.deleteJob(t, id, rec)
+		return nil
+	}
+
+	c, err := ParseCron(rec.Cron)
+	if err != nil {
+		return xerrors.Errorf("failed to parse schedule of job %s: %w", id, err)
+	}
+
+	rec.Due = c.Next(now)
+	if rec.Due.IsZero() {
+		s.deleteJob(t, id, rec)
+		return nil
+	}
+
+	return s.putJob(t, id, *rec)
+}
+
+// backoff returns how long to wait before retrying a job that failed
+// attempts times.
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	d := s.RetryBackoff
+	for i := 1; i < attempts && d < s.MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > s.MaxRetryBackoff {
+		d = s.MaxRetryBackoff
+	}
+
+	return d
+}
+
+func (s *Scheduler) getJob(t storage.ReadTransaction, id string) (*jobRecord, error) {
+	raw, err := t.Get(s.fmtJobKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	var rec jobRecord
+	err = json.Unmarshal(raw, &rec)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode job %s: %w", id, err)
+	}
+
+	return &rec, nil
+}
+
+func (s *Scheduler) setJob(t storage.Transaction, id string, rec jobRecord) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return xerrors.Errorf("failed to encode job %s: %w", id, err)
+	}
+
+	t.Set(s.fmtJobKey(id), raw)
+	return nil
+}
+
+// putJob stores rec and queues it at its due time.
+func (s *Scheduler) putJob(t storage.Transaction, id string, rec jobRecord) error {
+	err := s.setJob(t, id, rec)
+	if err != nil {
+		return err
+	}
+
+	t.Set(s.fmtQueueKey(rec.Due, id), []byte{})
+	return nil
+}
+
+// deleteJob deletes rec and its queue and lease entries.
+func (s *Scheduler) deleteJob(t storage.Transaction, id string, rec *jobRecord) {
+	t.Clear(s.fmtJobKey(id))
+	t.Clear(s.fmtQueueKey(rec.Due, id))
+	if rec.Lease != nil {
+		t.Clear(s.fmtRunningKey(rec.Lease.Until, id))
+	}
+}
+
+func (s *Scheduler) fmtJobKey(id string) storage.Key {
+	return s.dir.Pack(tuple.Tuple{"jobs", id})
+}
+
+func (s *Scheduler) fmtQueueKey(due time.Time, id string) storage.Key {
+	return s.dir.Pack(tuple.Tuple{"queue", due.UnixNano(), id})
+}
+
+func (s *Scheduler) fmtRunningKey(until time.Time, id string) storage.Key {
+	return s.dir.Pack(tuple.Tuple{"running", until.UnixNano(), id})
+}
+
+// unpackID returns the job id of a queue or running key.
+func (s *Scheduler) unpackID(key storage.Key) (string, error) {
+	tup, err := s.dir.Unpack(key)
+	if err != nil {
+		return "", xerrors.Errorf("failed to unpack job key: %w", err)
+	}
+
+	if len(tup) != 3 {
+		return "", xerrors.Errorf("unexpected job key %s", key)
+	}
+	id, ok := tup[2].(string)
+	if !ok {
+		return "", xerrors.Errorf("unexpected job key %s", key)
+	}
+
+	return id, nil
+}
+
+// newJobID returns a random id for a job or lease.
+func newJobID() string {
+	var raw [8]byte
+	_, _ = rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
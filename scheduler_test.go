@@ -0,0 +1,375 @@
+package rikka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+type reminder struct {
+	ChannelID disgord.Snowflake
+	Text      string
+}
+
+// testClock is a settable time for schedulers.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestScheduler(t *testing.T, store storage.Store, clock *testClock) *Scheduler {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(store, cfg)
+	r.Scheduler.now = clock.Now
+
+	return r.Scheduler
+}
+
+// runDue runs the due jobs of s and waits for them to finish.
+func runDue(t *testing.T, s *Scheduler) int {
+	t.Helper()
+
+	n, err := s.RunDue(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.wait()
+
+	return n
+}
+
+func TestSchedulerOneShot(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+
+	var got []reminder
+	s.Handle("remind", func(ctx context.Context, _ disgord.Session, job *Job, payload reminder) error {
+		got = append(got, payload)
+		return nil
+	})
+
+	_, err := s.Schedule("remind", clock.Now().Add(time.Hour), reminder{ChannelID: 1 << 40, Text: "later"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Schedule("remind", clock.Now().Add(time.Minute), reminder{ChannelID: 2 << 40, Text: "sooner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := runDue(t, s); n != 0 {
+		t.Fatalf("ran %d jobs before they were due", n)
+	}
+
+	clock.Add(time.Minute)
+	if n := runDue(t, s); n != 1 || len(got) != 1 || got[0].Text != "sooner" {
+		t.Fatalf("ran %d jobs with payloads %v, want only the sooner one", n, got)
+	}
+
+	clock.Add(2 * time.Hour)
+	if n := runDue(t, s); n != 1 || len(got) != 2 || got[1] != (reminder{ChannelID: 1 << 40, Text: "later"}) {
+		t.Fatalf("ran %d jobs with payloads %v, want the later one", n, got)
+	}
+
+	if n := runDue(t, s); n != 0 {
+		t.Fatalf("ran %d jobs again", n)
+	}
+}
+
+func TestSchedulerValidates(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+	s.Handle("remind", func(ctx context.Context, _ disgord.Session, job *Job, payload reminder) error {
+		return nil
+	})
+
+	if _, err := s.Schedule("missing", clock.Now(), reminder{}); err == nil {
+		t.Error("scheduled a job without a handler")
+	}
+	if _, err := s.Schedule("remind", clock.Now(), "text"); err == nil {
+		t.Error("scheduled a job with the wrong payload type")
+	}
+	if _, err := s.ScheduleRecurring("remind", "every day", reminder{}); err == nil {
+		t.Error("scheduled a job with an invalid cron expression")
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+	s.MaxAttempts = 3
+
+	var attempts []int
+	s.Handle("flaky", func(ctx context.Context, _ disgord.Session, job *Job, payload struct{}) error {
+		attempts = append(attempts, job.Attempt)
+		return xerrors.New("unavailable")
+	})
+
+	_, err := s.Schedule("flaky", clock.Now(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runDue(t, s)
+	clock.Add(s.RetryBackoff - time.Second)
+	if n := runDue(t, s); n != 0 {
+		t.Fatal("retried before the backoff passed")
+	}
+
+	clock.Add(time.Second)
+	runDue(t, s)
+	clock.Add(s.RetryBackoff)
+	if n := runDue(t, s); n != 0 {
+		t.Fatal("retried before the doubled backoff passed")
+	}
+
+	clock.Add(s.RetryBackoff)
+	runDue(t, s)
+	clock.Add(time.Hour)
+	if n := runDue(t, s); n != 0 {
+		t.Fatal("retried after the last attempt")
+	}
+
+	if len(attempts) != 3 || attempts[0] != 0 || attempts[2] != 2 {
+		t.Errorf("attempts = %v, want [0 1 2]", attempts)
+	}
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+
+	var due []time.Time
+	s.Handle("announce", func(ctx context.Context, _ disgord.Session, job *Job, payload string) error {
+		due = append(due, job.Due)
+		return nil
+	})
+
+	id, err := s.ScheduleRecurring("announce", "0 * * * *", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		clock.Add(time.Hour)
+		if n := runDue(t, s); n != 1 {
+			t.Fatalf("ran %d jobs in hour %d, want 1", n, i)
+		}
+	}
+	if len(due) != 3 || !due[2].Equal(time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ran at %v, want every hour", due)
+	}
+
+	err = s.Cancel(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	if n := runDue(t, s); n != 0 {
+		t.Fatal("ran a cancelled job")
+	}
+	if err := s.Cancel(id); !xerrors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel() = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestSchedulerAtMostOnce(t *testing.T) {
+	var (
+		store = storage.NewMemory()
+		clock = &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+		mu   sync.Mutex
+		runs = map[int]int{}
+	)
+
+	schedulers := make([]*Scheduler, 4)
+	for i := range schedulers {
+		schedulers[i] = newTestScheduler(t, store, clock)
+		schedulers[i].Handle("count", func(ctx context.Context, _ disgord.Session, job *Job, payload int) error {
+			mu.Lock()
+			runs[payload]++
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	const jobs = 50
+	for i := 0; i < jobs; i++ {
+		_, err := schedulers[0].Schedule("count", clock.Now(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range schedulers {
+		wg.Add(1)
+		go func(s *Scheduler) {
+			defer wg.Done()
+			for i := 0; i < jobs; i++ {
+				if _, err := s.RunDue(context.Background(), nil); err != nil {
+					t.Error(err)
+					return
+				}
+				s.wait()
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	if len(runs) != jobs {
+		t.Errorf("ran %d jobs, want %d", len(runs), jobs)
+	}
+	for id, n := range runs {
+		if n != 1 {
+			t.Errorf("job %d ran %d times", id, n)
+		}
+	}
+}
+
+func TestSchedulerEnsureRecurring(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC)}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+
+	var runs int
+	s.Handle("tick", func(ctx context.Context, _ disgord.Session, job *Job, payload struct{}) error {
+		runs++
+		return nil
+	})
+
+	// Ensuring the job again, e.g. after a restart, doesn't add another one.
+	for i := 0; i < 3; i++ {
+		if err := s.EnsureRecurring("tick", "@hourly", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.Add(time.Hour)
+	if n := runDue(t, s); n != 1 || runs != 1 {
+		t.Fatalf("ran %d jobs, want 1", n)
+	}
+
+	// Changing the schedule replaces the job.
+	if err := s.EnsureRecurring("tick", "0 0 * * *", nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour)
+	if n := runDue(t, s); n != 0 {
+		t.Fatalf("ran %d jobs on the old schedule", n)
+	}
+	clock.Add(24 * time.Hour)
+	if n := runDue(t, s); n != 1 {
+		t.Fatalf("ran %d jobs on the new schedule, want 1", n)
+	}
+}
+
+func TestSchedulerConcurrency(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := newTestScheduler(t, storage.NewMemory(), clock)
+	s.MaxConcurrentJobs = 2
+
+	var (
+		started = make(chan struct{}, 3)
+		release = make(chan struct{})
+	)
+	s.Handle("block", func(ctx context.Context, _ disgord.Session, job *Job, payload int) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := s.Schedule("block", clock.Now(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// RunDue returns while the jobs it started are still running.
+	n, err := s.RunDue(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("started %d jobs, want 2", n)
+	}
+	<-started
+	<-started
+
+	n, err = s.RunDue(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("started %d jobs while busy, want 0", n)
+	}
+
+	close(release)
+	s.wait()
+
+	if n := runDue(t, s); n != 1 {
+		t.Fatalf("started %d jobs once idle, want 1", n)
+	}
+}
+
+func TestSchedulerExpiredLease(t *testing.T) {
+	var (
+		store = storage.NewMemory()
+		clock = &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+		s     = newTestScheduler(t, store, clock)
+	)
+
+	var runs int
+	s.Handle("once", func(ctx context.Context, _ disgord.Session, job *Job, payload string) error {
+		runs++
+		return nil
+	})
+	_, err := s.Schedule("once", clock.Now(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim the job as if the instance stopped before running it.
+	claimed, err := s.claim(schedulerBatch)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claimed %d jobs: %v", len(claimed), err)
+	}
+
+	other := newTestScheduler(t, store, clock)
+	other.Handle("once", func(ctx context.Context, _ disgord.Session, job *Job, payload string) error {
+		runs++
+		return nil
+	})
+	clock.Add(3 * s.JobTimeout)
+	if n := runDue(t, other); n != 0 || runs != 0 {
+		t.Fatalf("ran a job whose lease expired")
+	}
+
+	// The stopped instance's late finish is ignored.
+	err = s.finish(claimed[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := runDue(t, other); n != 0 {
+		t.Fatal("ran a job after it finished")
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
+	"golang.org/x/xerrors"
+)
+
+// FDB is a Store backed by FoundationDB.
+type FDB struct {
+	db fdb.Database
+}
+
+// NewFDB returns a Store backed by db.
+func NewFDB(db fdb.Database) *FDB {
+	return &FDB{db: db}
+}
+
+func (s *FDB) Transact(fn func(t Transaction) error) error {
+	_, err := s.db.Transact(func(t fdb.Transaction) (interface{}, error) {
+		return nil, fn(fdbTransaction{fdbReadTransaction{t}, t})
+	})
+
+	return err
+}
+
+func (s *FDB) ReadTransact(fn func(t ReadTransaction) error) error {
+	_, err := s.db.ReadTransact(func(t fdb.ReadTransaction) (interface{}, error) {
+		return nil, fn(fdbReadTransaction{t})
+	})
+
+	return err
+}
+
+func (s *FDB) Directory(path ...string) (Subspace, error) {
+	dir, err := directory.CreateOrOpen(s.db, path, nil)
+	if err != nil {
+		return Subspace{}, xerrors.Errorf("failed to create directory: %w", err)
+	}
+
+	return NewSubspace(dir.Bytes()), nil
+}
+
+// Ping reads a key in a single attempt that fails when ctx is done, unlike
+// ReadTransact which retries until it succeeds.
+func (s *FDB) Ping(ctx context.Context) error {
+	t, err := s.db.CreateTransaction()
+	if err != nil {
+		return xerrors.Errorf("failed to create transaction: %w", err)
+	}
+	defer t.Cancel()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = t.Options().SetTimeout(time.Until(deadline).Milliseconds())
+		if err != nil {
+			return xerrors.Errorf("failed to set timeout: %w", err)
+		}
+	}
+
+	_, err = t.Get(fdb.Key("rikka/ping")).Get()
+	return err
+}
+
+type fdbReadTransaction struct {
+	t fdb.ReadTransaction
+}
+
+func (t fdbReadTransaction) Get(key Key) ([]byte, error) {
+	return t.t.Get(fdb.Key(key)).Get()
+}
+
+func (t fdbReadTransaction) GetRange(r Range, opts RangeOptions) ([]KeyValue, error) {
+	kvs, err := t.t.GetRange(fdb.KeyRange{Begin: fdb.Key(r.Begin), End: fdb.Key(r.End)}, fdb.RangeOptions{
+		Limit:   opts.Limit,
+		Reverse: opts.Reverse,
+	}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]KeyValue, len(kvs))
+	for i, e := range kvs {
+		out[i] = KeyValue{Key: Key(e.Key), Value: e.Value}
+	}
+
+	return out, nil
+}
+
+func (t fdbReadTransaction) Snapshot() ReadTransaction {
+	return fdbReadTransaction{t.t.Snapshot()}
+}
+
+type fdbTransaction struct {
+	fdbReadTransaction
+	w fdb.Transaction
+}
+
+func (t fdbTransaction) Set(key Key, value []byte) {
+	t.w.Set(fdb.Key(key), value)
+}
+
+func (t fdbTransaction) Clear(key Key) {
+	t.w.Clear(fdb.Key(key))
+}
+
+func (t fdbTransaction) ClearRange(r Range) {
+	t.w.ClearRange(fdb.KeyRange{Begin: fdb.Key(r.Begin), End: fdb.Key(r.End)})
+}
+
+func (t fdbTransaction) Add(key Key, delta int64) {
+	t.w.Add(fdb.Key(key), EncodeInt(delta))
+}
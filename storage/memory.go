@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+)
+
+// MaxMemoryAttempts is how many times Memory tries a conflicting transaction
+// before giving up.
+const MaxMemoryAttempts = 100
+
+// ErrConflict is returned when a transaction keeps conflicting with others.
+var ErrConflict = xerrors.New("transaction conflicted too many times")
+
+// Memory is a Store kept in memory, for tests and local development. Like
+// FDB, reads happen at the version the transaction started at and a
+// transaction is retried if a key it read was changed before it commits.
+type Memory struct {
+	mu      sync.Mutex
+	version int64
+	// keys is every key with a history, sorted.
+	keys    []string
+	history map[string][]memValue
+	// commits are the writes of recent commits, used to detect conflicts.
+	commits []memCommit
+	// active counts the running transactions at each read version.
+	active map[int64]int
+	dirs   map[string]Subspace
+}
+
+type memValue struct {
+	version int64
+	// value is nil if the key was cleared.
+	value []byte
+}
+
+type memCommit struct {
+	version int64
+	keys    []Key
+	ranges  []Range
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		history: map[string][]memValue{},
+		active:  map[int64]int{},
+		dirs:    map[string]Subspace{},
+	}
+}
+
+func (m *Memory) Transact(fn func(t Transaction) error) error {
+	for i := 0; i < MaxMemoryAttempts; i++ {
+		ok, err := m.attempt(func(t *memTransaction) error { return fn(t) })
+		if err != nil || ok {
+			return err
+		}
+	}
+
+	return ErrConflict
+}
+
+func (m *Memory) ReadTransact(fn func(t ReadTransaction) error) error {
+	_, err := m.attempt(func(t *memTransaction) error { return fn(memSnapshot{t, false}) })
+	return err
+}
+
+func (m *Memory) Directory(path ...string) (Subspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := strings.Join(path, "\x00")
+	if s, ok := m.dirs[name]; ok {
+		return s, nil
+	}
+
+	s := NewSubspace(tuple.Tuple{int64(len(m.dirs) + 1)}.Pack())
+	m.dirs[name] = s
+
+	return s, nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// attempt runs fn in a transaction and reports whether it committed.
+func (m *Memory) attempt(fn func(t *memTransaction) error) (bool, error) {
+	m.mu.Lock()
+	t := &memTransaction{m: m, readVersion: m.version}
+	m.active[t.readVersion]++
+	m.mu.Unlock()
+
+	defer m.end(t)
+
+	err := fn(t)
+	if err != nil {
+		return false, err
+	}
+
+	return m.commit(t), nil
+}
+
+func (m *Memory) end(t *memTransaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.active[t.readVersion]--
+	if m.active[t.readVersion] == 0 {
+		delete(m.active, t.readVersion)
+	}
+	m.prune()
+}
+
+func (m *Memory) commit(t *memTransaction) bool {
+	if len(t.ops) == 0 {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.commits {
+		if c.version > t.readVersion && t.conflicts(c) {
+			return false
+		}
+	}
+
+	// Apply the writes on top of the latest values.
+	staged := map[string][]byte{}
+	latest := func(k string) []byte {
+		if v, ok := staged[k]; ok {
+			return v
+		}
+		return m.read(k, m.version)
+	}
+
+	c := memCommit{version: m.version + 1}
+	for _, op := range t.ops {
+		switch op.kind {
+		case opSet:
+			staged[string(op.key)] = op.value
+			c.keys = append(c.keys, op.key)
+		case opClear:
+			staged[string(op.key)] = nil
+			c.keys = append(c.keys, op.key)
+		case opAdd:
+			staged[string(op.key)] = EncodeInt(DecodeInt(latest(string(op.key))) + op.delta)
+			c.keys = append(c.keys, op.key)
+		case opClearRange:
+			for _, k := range m.keysIn(op.rng) {
+				staged[k] = nil
+			}
+			for k := range staged {
+				if op.rng.contains(Key(k)) {
+					staged[k] = nil
+				}
+			}
+			c.ranges = append(c.ranges, op.rng)
+		}
+	}
+
+	m.version = c.version
+	for k, v := range staged {
+		if _, ok := m.history[k]; !ok {
+			if v == nil {
+				continue
+			}
+			i := sort.SearchStrings(m.keys, k)
+			m.keys = append(m.keys, "")
+			copy(m.keys[i+1:], m.keys[i:])
+			m.keys[i] = k
+		}
+		m.history[k] = append(m.history[k], memValue{version: m.version, value: v})
+	}
+	m.commits = append(m.commits, c)
+
+	return true
+}
+
+// prune forgets commits and old values no running transaction can see.
+func (m *Memory) prune() {
+	oldest := m.version
+	for v := range m.active {
+		if v < oldest {
+			oldest = v
+		}
+	}
+
+	i := 0
+	for i < len(m.commits) && m.commits[i].version <= oldest {
+		i++
+	}
+	pruned := m.commits[:i]
+	m.commits = append(m.commits[:0:0], m.commits[i:]...)
+
+	for _, c := range pruned {
+		for _, k := range c.keys {
+			m.pruneKey(string(k), oldest)
+		}
+		for _, r := range c.ranges {
+			for _, k := range m.keysIn(r) {
+				m.pruneKey(k, oldest)
+			}
+		}
+	}
+}
+
+// pruneKey drops the values of k older than the one visible at version.
+func (m *Memory) pruneKey(k string, version int64) {
+	hist := m.history[k]
+	if len(hist) == 0 {
+		return
+	}
+
+	i := len(hist) - 1
+	for i > 0 && hist[i].version > version {
+		i--
+	}
+	hist = hist[i:]
+
+	if len(hist) == 1 && hist[0].value == nil && hist[0].version <= version {
+		delete(m.history, k)
+		j := sort.SearchStrings(m.keys, k)
+		m.keys = append(m.keys[:j], m.keys[j+1:]...)
+		return
+	}
+
+	m.history[k] = hist
+}
+
+// read returns the value of k at version. m.mu must be held.
+func (m *Memory) read(k string, version int64) []byte {
+	hist := m.history[k]
+	for i := len(hist) - 1; i >= 0; i-- {
+		if hist[i].version <= version {
+			return hist[i].value
+		}
+	}
+
+	return nil
+}
+
+// keysIn returns every key with a history within r. m.mu must be held.
+func (m *Memory) keysIn(r Range) []string {
+	begin := sort.SearchStrings(m.keys, string(r.Begin))
+	end := sort.SearchStrings(m.keys, string(r.End))
+	if end < begin {
+		return nil
+	}
+
+	return append([]string(nil), m.keys[begin:end]...)
+}
+
+type opKind int
+
+const (
+	opSet opKind = iota
+	opClear
+	opClearRange
+	opAdd
+)
+
+type memOp struct {
+	kind  opKind
+	key   Key
+	rng   Range
+	value []byte
+	delta int64
+}
+
+// apply returns the value of key after op, given its value before.
+func (op memOp) apply(key Key, value []byte) []byte {
+	switch op.kind {
+	case opSet:
+		if string(op.key) == string(key) {
+			return op.value
+		}
+	case opClear:
+		if string(op.key) == string(key) {
+			return nil
+		}
+	case opClearRange:
+		if op.rng.contains(key) {
+			return nil
+		}
+	case opAdd:
+		if string(op.key) == string(key) {
+			return EncodeInt(DecodeInt(value) + op.delta)
+		}
+	}
+
+	return value
+}
+
+type memTransaction struct {
+	m           *Memory
+	readVersion int64
+	ops         []memOp
+
+	readKeys   []Key
+	readRanges []Range
+}
+
+// conflicts returns true if c wrote anything t read.
+func (t *memTransaction) conflicts(c memCommit) bool {
+	for _, k := range t.readKeys {
+		for _, w := range c.keys {
+			if string(k) == string(w) {
+				return true
+			}
+		}
+		for _, w := range c.ranges {
+			if w.contains(k) {
+				return true
+			}
+		}
+	}
+
+	for _, r := range t.readRanges {
+		for _, w := range c.keys {
+			if r.contains(w) {
+				return true
+			}
+		}
+		for _, w := range c.ranges {
+			if string(r.Begin) < string(w.End) && string(w.Begin) < string(r.End) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (t *memTransaction) get(key Key, snapshot bool) []byte {
+	if !snapshot {
+		t.readKeys = append(t.readKeys, copyBytes(key))
+	}
+
+	t.m.mu.Lock()
+	value := t.m.read(string(key), t.readVersion)
+	t.m.mu.Unlock()
+
+	for _, op := range t.ops {
+		value = op.apply(key, value)
+	}
+
+	return copyBytes(value)
+}
+
+func (t *memTransaction) getRange(r Range, opts RangeOptions, snapshot bool) []KeyValue {
+	if !snapshot {
+		t.readRanges = append(t.readRanges, Range{Begin: copyBytes(r.Begin), End: copyBytes(r.End)})
+	}
+
+	t.m.mu.Lock()
+	keys := t.m.keysIn(r)
+	values := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		values[k] = t.m.read(k, t.readVersion)
+	}
+	t.m.mu.Unlock()
+
+	for _, op := range t.ops {
+		if (op.kind == opSet || op.kind == opAdd) && r.contains(op.key) {
+			if _, ok := values[string(op.key)]; !ok {
+				values[string(op.key)] = nil
+				keys = append(keys, string(op.key))
+			}
+		}
+	}
+	sort.Strings(keys)
+	if opts.Reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	var kvs []KeyValue
+	for _, k := range keys {
+		value := values[k]
+		for _, op := range t.ops {
+			value = op.apply(Key(k), value)
+		}
+		if value == nil {
+			continue
+		}
+
+		kvs = append(kvs, KeyValue{Key: Key(k), Value: copyBytes(value)})
+		if opts.Limit > 0 && len(kvs) == opts.Limit {
+			break
+		}
+	}
+
+	return kvs
+}
+
+func (t *memTransaction) Get(key Key) ([]byte, error) {
+	return t.get(key, false), nil
+}
+
+func (t *memTransaction) GetRange(r Range, opts RangeOptions) ([]KeyValue, error) {
+	return t.getRange(r, opts, false), nil
+}
+
+func (t *memTransaction) Snapshot() ReadTransaction {
+	return memSnapshot{t, true}
+}
+
+func (t *memTransaction) Set(key Key, value []byte) {
+	if value == nil {
+		value = []byte{}
+	}
+	t.ops = append(t.ops, memOp{kind: opSet, key: copyBytes(key), value: copyBytes(value)})
+}
+
+func (t *memTransaction) Clear(key Key) {
+	t.ops = append(t.ops, memOp{kind: opClear, key: copyBytes(key)})
+}
+
+func (t *memTransaction) ClearRange(r Range) {
+	t.ops = append(t.ops, memOp{kind: opClearRange, rng: Range{Begin: copyBytes(r.Begin), End: copyBytes(r.End)}})
+}
+
+func (t *memTransaction) Add(key Key, delta int64) {
+	t.ops = append(t.ops, memOp{kind: opAdd, key: copyBytes(key), delta: delta})
+}
+
+// memSnapshot is the read only view of a transaction given to ReadTransact
+// and returned by Snapshot.
+type memSnapshot struct {
+	t        *memTransaction
+	snapshot bool
+}
+
+func (s memSnapshot) Get(key Key) ([]byte, error) {
+	return s.t.get(key, s.snapshot), nil
+}
+
+func (s memSnapshot) GetRange(r Range, opts RangeOptions) ([]KeyValue, error) {
+	return s.t.getRange(r, opts, s.snapshot), nil
+}
+
+func (s memSnapshot) Snapshot() ReadTransaction {
+	return memSnapshot{s.t, true}
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
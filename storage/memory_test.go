@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+func TestMemoryReadYourWrites(t *testing.T) {
+	m := NewMemory()
+	dir, _ := m.Directory("test")
+
+	err := m.Transact(func(t Transaction) error {
+		t.Set(dir.Pack(tuple.Tuple{"a"}), []byte("1"))
+		t.Set(dir.Pack(tuple.Tuple{"b"}), []byte("2"))
+		t.Set(dir.Pack(tuple.Tuple{"c"}), []byte("3"))
+		t.Clear(dir.Pack(tuple.Tuple{"b"}))
+		t.Add(dir.Pack(tuple.Tuple{"n"}), 5)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Transact(func(tr Transaction) error {
+		tr.Add(dir.Pack(tuple.Tuple{"n"}), -2)
+		if v, _ := tr.Get(dir.Pack(tuple.Tuple{"n"})); DecodeInt(v) != 3 {
+			t.Errorf("n = %d, want 3", DecodeInt(v))
+		}
+
+		tr.Set(dir.Pack(tuple.Tuple{"d"}), []byte("4"))
+		kvs, _ := tr.GetRange(dir.Range(), RangeOptions{})
+		var got []string
+		for _, e := range kvs {
+			got = append(got, string(e.Value))
+		}
+		if len(got) != 4 || got[0] != "1" || got[1] != "3" || got[2] != "4" {
+			t.Errorf("range = %q, want [1 3 4 n]", got)
+		}
+
+		kvs, _ = tr.GetRange(dir.Range(), RangeOptions{Limit: 1, Reverse: true})
+		if len(kvs) != 1 || DecodeInt(kvs[0].Value) != 3 {
+			t.Errorf("reverse range = %v", kvs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryConflict(t *testing.T) {
+	m := NewMemory()
+	key := Key("counter")
+
+	// Increment the counter while another transaction increments it in the
+	// middle. The first attempt must be retried and see the other write.
+	var attempts int
+	err := m.Transact(func(tr Transaction) error {
+		attempts++
+		v, _ := tr.Get(key)
+		if attempts == 1 {
+			err := m.Transact(func(tr Transaction) error {
+				tr.Set(key, EncodeInt(10))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		tr.Set(key, EncodeInt(DecodeInt(v)+1))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+
+	var v []byte
+	m.ReadTransact(func(tr ReadTransaction) error {
+		v, _ = tr.Get(key)
+		return nil
+	})
+	if DecodeInt(v) != 11 {
+		t.Fatalf("counter = %d, want 11", DecodeInt(v))
+	}
+}
+
+func TestMemorySnapshotNoConflict(t *testing.T) {
+	m := NewMemory()
+	key := Key("k")
+
+	var attempts int
+	err := m.Transact(func(tr Transaction) error {
+		attempts++
+		tr.Snapshot().Get(key)
+		if attempts == 1 {
+			m.Transact(func(tr Transaction) error {
+				tr.Set(key, []byte("x"))
+				return nil
+			})
+		}
+		tr.Set(Key("other"), []byte("y"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestMemoryReadVersion(t *testing.T) {
+	m := NewMemory()
+	key := Key("k")
+	m.Transact(func(tr Transaction) error {
+		tr.Set(key, []byte("old"))
+		return nil
+	})
+
+	m.ReadTransact(func(tr ReadTransaction) error {
+		m.Transact(func(tr Transaction) error {
+			tr.ClearRange(Range{Begin: Key(""), End: Key("\xff")})
+			return nil
+		})
+
+		if v, _ := tr.Get(key); string(v) != "old" {
+			t.Errorf("got %q, want the value at the read version", v)
+		}
+		return nil
+	})
+
+	m.ReadTransact(func(tr ReadTransaction) error {
+		if v, _ := tr.Get(key); v != nil {
+			t.Errorf("got %q after clear", v)
+		}
+		return nil
+	})
+
+	if len(m.keys) != 0 || len(m.commits) != 0 {
+		t.Errorf("expected history to be pruned, got %d keys and %d commits", len(m.keys), len(m.commits))
+	}
+}
+
+func TestMemoryDirectories(t *testing.T) {
+	m := NewMemory()
+	a, _ := m.Directory("rikka", "logs")
+	b, _ := m.Directory("rikka", "logs", "messages")
+	if a.Contains(b.Pack(tuple.Tuple{1})) {
+		t.Fatal("directories overlap")
+	}
+	if again, _ := m.Directory("rikka", "logs"); string(again.Bytes()) != string(a.Bytes()) {
+		t.Fatal("directory prefix changed")
+	}
+}
@@ -0,0 +1,142 @@
+// Package storage is the key-value store the bot keeps its state in. It is
+// modelled after FoundationDB: keys are ordered bytes, every read and write
+// happens in a serializable transaction, and transactions are retried when
+// they conflict with another one.
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+// Store runs transactions against a key-value store.
+type Store interface {
+	// Transact runs fn in a transaction and commits it. fn may be called more
+	// than once if the transaction conflicts, so it must not have side
+	// effects outside of the transaction. If fn returns an error the
+	// transaction is discarded and the error is returned.
+	Transact(fn func(t Transaction) error) error
+	// ReadTransact runs fn in a read only transaction.
+	ReadTransact(fn func(t ReadTransaction) error) error
+	// Directory returns the subspace for a path, creating it if needed.
+	// Subspaces of different paths never overlap, even if one path is a
+	// prefix of another.
+	Directory(path ...string) (Subspace, error)
+	// Ping checks that the store is reachable without retrying.
+	Ping(ctx context.Context) error
+}
+
+// ReadTransaction reads keys. Reads see the writes made earlier in the same
+// transaction.
+type ReadTransaction interface {
+	// Get returns the value of key, or nil if it is not set.
+	Get(key Key) ([]byte, error)
+	// GetRange returns the keys in r in order.
+	GetRange(r Range, opts RangeOptions) ([]KeyValue, error)
+	// Snapshot returns a view of the transaction whose reads do not cause
+	// conflicts. Use it for reads whose result may be slightly stale.
+	Snapshot() ReadTransaction
+}
+
+// Transaction reads and writes keys.
+type Transaction interface {
+	ReadTransaction
+
+	Set(key Key, value []byte)
+	Clear(key Key)
+	ClearRange(r Range)
+	// Add atomically adds delta to the little endian integer stored at key,
+	// treating a missing key as zero. It does not cause conflicts.
+	Add(key Key, delta int64)
+}
+
+// Key is a key in the store.
+type Key []byte
+
+func (k Key) String() string {
+	return fdb.Printable(k)
+}
+
+// KeyValue is a key and its value.
+type KeyValue struct {
+	Key   Key
+	Value []byte
+}
+
+// Range is the keys from Begin up to but not including End.
+type Range struct {
+	Begin Key
+	End   Key
+}
+
+func (r Range) contains(k Key) bool {
+	return string(k) >= string(r.Begin) && string(k) < string(r.End)
+}
+
+// RangeOptions limits a range read.
+type RangeOptions struct {
+	// Limit is the maximum number of keys returned. Zero means no limit.
+	Limit int
+	// Reverse returns keys in descending order.
+	Reverse bool
+}
+
+// Subspace is a range of keys sharing a prefix, with keys encoded as tuples.
+type Subspace struct {
+	s subspace.Subspace
+}
+
+// NewSubspace returns the subspace of keys starting with prefix.
+func NewSubspace(prefix []byte) Subspace {
+	return Subspace{s: subspace.FromBytes(prefix)}
+}
+
+// Bytes returns the prefix of the subspace.
+func (s Subspace) Bytes() []byte {
+	return s.s.Bytes()
+}
+
+// Sub returns the subspace of s prefixed by the tuple of el.
+func (s Subspace) Sub(el ...tuple.TupleElement) Subspace {
+	return Subspace{s: s.s.Sub(el...)}
+}
+
+// Pack returns the key of t within s.
+func (s Subspace) Pack(t tuple.Tuple) Key {
+	return Key(s.s.Pack(t))
+}
+
+// Unpack returns the tuple of a key within s.
+func (s Subspace) Unpack(k Key) (tuple.Tuple, error) {
+	return s.s.Unpack(fdb.Key(k))
+}
+
+// Contains returns true if k is within s.
+func (s Subspace) Contains(k Key) bool {
+	return s.s.Contains(fdb.Key(k))
+}
+
+// Range returns every key within s.
+func (s Subspace) Range() Range {
+	begin, end := s.s.FDBRangeKeys()
+	return Range{Begin: Key(begin.FDBKey()), End: Key(end.FDBKey())}
+}
+
+// EncodeInt encodes i the way Add expects.
+func EncodeInt(i int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(i))
+	return b
+}
+
+// DecodeInt decodes an integer written by Add or EncodeInt. A nil value is
+// zero.
+func DecodeInt(b []byte) int64 {
+	var buf [8]byte
+	copy(buf[:], b)
+	return int64(binary.LittleEndian.Uint64(buf[:]))
+}
@@ -0,0 +1,239 @@
+package rikka
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"cdr.dev/slog"
+	"github.com/andersfylling/disgord"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"golang.org/x/xerrors"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+// MaxSuggestions is the maximum amount of names suggested for a typo.
+const MaxSuggestions = 3
+
+// suggestions stores the guilds that turned off suggestions for unknown
+// commands. Suggestions are on unless they are turned off.
+type suggestions struct {
+	dir storage.Subspace
+
+	mu    sync.RWMutex
+	cache map[disgord.Snowflake]bool
+	// gens is incremented every time a guild's setting changes, so a fill
+	// that read it before the change isn't cached.
+	gens map[disgord.Snowflake]uint64
+}
+
+// SuggestionsEnabled returns true if unknown commands in a guild are answered
+// with the closest command names. Suggestions are always enabled in private
+// messages, and are treated as disabled if the setting fails to load.
+func (r *Rikka) SuggestionsEnabled(guildID disgord.Snowflake) bool {
+	if guildID.IsZero() {
+		return true
+	}
+
+	r.suggestions.mu.RLock()
+	disabled, ok := r.suggestions.cache[guildID]
+	gen := r.suggestions.gens[guildID]
+	r.suggestions.mu.RUnlock()
+	if ok {
+		return !disabled
+	}
+
+	var raw []byte
+	err := r.ReadTransact(func(t storage.ReadTransaction) error {
+		var err error
+		raw, err = t.Snapshot().Get(r.fmtSuggestionsKey(guildID))
+		return err
+	})
+	if err != nil {
+		r.Log.Error(r.ctx, "failed to load suggestion setting", slog.Error(err), slog.F("guild_id", guildID))
+		return false
+	}
+
+	disabled = raw != nil
+	r.cacheSuggestions(guildID, gen, disabled)
+	return !disabled
+}
+
+// cacheSuggestions caches whether suggestions are disabled in a guild, unless
+// the setting changed since gen was read.
+func (r *Rikka) cacheSuggestions(guildID disgord.Snowflake, gen uint64, disabled bool) {
+	r.suggestions.mu.Lock()
+	defer r.suggestions.mu.Unlock()
+
+	if r.suggestions.gens[guildID] != gen {
+		return
+	}
+	r.suggestions.cache[guildID] = disabled
+}
+
+// SetSuggestions turns suggestions for unknown commands on or off in a
+// guild, e.g. because its prefix collides with another bot.
+func (r *Rikka) SetSuggestions(guildID disgord.Snowflake, enabled bool) error {
+	err := r.Transact(func(t storage.Transaction) error {
+		if enabled {
+			t.Clear(r.fmtSuggestionsKey(guildID))
+		} else {
+			t.Set(r.fmtSuggestionsKey(guildID), []byte{})
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to transact suggestion setting: %w", err)
+	}
+
+	r.suggestions.mu.Lock()
+	delete(r.suggestions.cache, guildID)
+	r.suggestions.gens[guildID]++
+	r.suggestions.mu.Unlock()
+
+	return nil
+}
+
+func (r *Rikka) fmtSuggestionsKey(guildID disgord.Snowflake) storage.Key {
+	return r.suggestions.dir.Pack(tuple.Tuple{uint64(guildID)})
+}
+
+// suggestCommand replies to a message naming an unknown command with the
+// closest command names, if there are any and the guild has suggestions
+// enabled. Commands the author can't see in help are never suggested.
+func (r *Rikka) suggestCommand(s disgord.Session, mc *disgord.MessageCreate, name string) {
+	guildID := mc.Message.GuildID
+	if mc.Message.Author == nil || mc.Message.Author.Bot || !r.SuggestionsEnabled(guildID) {
+		return
+	}
+
+	var candidates []string
+	_, rtr := r.registered()
+	for key, rt := range rtr {
+		if rt.help.Section == HelpSectionOwner && !r.IsOwner(mc.Message.Author.ID) {
+			continue
+		}
+		if !r.ModuleEnabled(guildID, rt.module) {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+
+	matches := Suggest(name, candidates)
+	if len(matches) == 0 {
+		return
+	}
+
+	prefix := r.Prefixes(guildID)[0]
+	for i, e := range matches {
+		matches[i] = prefix + e
+	}
+	r.HandleError(mc.Ctx, s, mc.Message, NotFound("Unknown command `%s%s`. Did you mean %s?", prefix, name, quoteNames(matches, "or")), "Unknown command")
+}
+
+// Suggest returns the candidates closest to name by edit distance, closest
+// first. Candidates that are too far from name to likely be a typo of it are
+// left out, as is name itself.
+func Suggest(name string, candidates []string) []string {
+	name = strings.ToLower(name)
+	maxDistance := 1
+	if len(name) > 4 {
+		maxDistance = 2
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+
+	var (
+		matches []match
+		seen    = map[string]bool{}
+	)
+	for _, e := range candidates {
+		lower := strings.ToLower(e)
+		if lower == name || seen[lower] {
+			continue
+		}
+		seen[lower] = true
+
+		if d := editDistance(name, lower); d <= maxDistance {
+			matches = append(matches, match{name: e, distance: d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	if len(matches) > MaxSuggestions {
+		matches = matches[:MaxSuggestions]
+	}
+
+	names := make([]string, len(matches))
+	for i, e := range matches {
+		names[i] = e.name
+	}
+
+	return names
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters needed to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between the first i runes of a and the first j
+	// runes of b.
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+// quoteNames formats names as a list, e.g. "`a`, `b` or `c`".
+func quoteNames(names []string, conjunction string) string {
+	quoted := make([]string, len(names))
+	for i, e := range names {
+		quoted[i] = "`" + e + "`"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " " + conjunction + " " + quoted[len(quoted)-1]
+}
@@ -0,0 +1,91 @@
+package rikka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andersfylling/disgord"
+
+	"github.com/coadler/rikka2/storage"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"seen", "seen", 0},
+		{"sen", "seen", 1},
+		{"sene", "seen", 1},
+		{"mesages", "messages", 1},
+		{"prefx", "prefix", 1},
+		{"log", "ping", 3},
+		{"", "help", 4},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"seen", "lastseen", "help", "log", "logs", "ping", "prefix", "permissions", "perms"}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"sen", []string{"seen"}},
+		{"lgo", []string{"log"}},
+		{"perm", []string{"perms"}},
+		{"prefx", []string{"prefix"}},
+		{"lastsene", []string{"lastseen"}},
+		{"xyz", []string{}},
+		{"seen", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := Suggest(tt.name, candidates); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestionsSetting(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Token = "test"
+	r := New(storage.NewMemory(), cfg)
+
+	const guild = disgord.Snowflake(1)
+	if !r.SuggestionsEnabled(guild) {
+		t.Fatal("suggestions disabled by default")
+	}
+
+	err := r.SetSuggestions(guild, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.suggestions.cache = map[disgord.Snowflake]bool{}
+	if r.SuggestionsEnabled(guild) {
+		t.Fatal("suggestions enabled after turning them off")
+	}
+	if !r.SuggestionsEnabled(0) {
+		t.Fatal("suggestions disabled in private messages")
+	}
+
+	// A fill that read the setting before it changed isn't cached.
+	r.suggestions.mu.RLock()
+	gen := r.suggestions.gens[guild]
+	r.suggestions.mu.RUnlock()
+
+	err = r.SetSuggestions(guild, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.cacheSuggestions(guild, gen, true)
+	if !r.SuggestionsEnabled(guild) {
+		t.Fatal("stale fill was cached after suggestions were turned on")
+	}
+}
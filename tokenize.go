@@ -0,0 +1,186 @@
+package rikka
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a single argument of a command.
+type Token struct {
+	// Value is the argument with quotes, escapes and code fences removed.
+	Value string
+	// Raw is the unmodified message text starting at this argument.
+	Raw string
+}
+
+// Args are the tokenized arguments of a command.
+type Args []Token
+
+// Pop removes the first argument and returns its value, or an empty string if
+// there are no arguments left.
+func (a *Args) Pop() string {
+	if len(*a) == 0 {
+		return ""
+	}
+
+	arg := (*a)[0]
+	*a = (*a)[1:]
+	return arg.Value
+}
+
+// Rest returns the raw remainder of the message starting at the first
+// argument. If only a single argument is left its value is returned instead,
+// so a lone quoted string or code block is unwrapped.
+func (a Args) Rest() string {
+	switch len(a) {
+	case 0:
+		return ""
+	case 1:
+		return a[0].Value
+	default:
+		return strings.TrimSpace(a[0].Raw)
+	}
+}
+
+// Strings returns the values of all arguments.
+func (a Args) Strings() []string {
+	strs := make([]string, len(a))
+	for i, e := range a {
+		strs[i] = e.Value
+	}
+
+	return strs
+}
+
+// Tokenize splits a message into arguments on whitespace. "Double quoted" and
+// 'single quoted' strings as well as ```code blocks``` are kept together as a
+// single argument. A backslash escapes quotes, whitespace and backslashes.
+func Tokenize(s string) Args {
+	var (
+		args Args
+		i    int
+	)
+
+	for {
+		i += leadingSpace(s[i:])
+		if i >= len(s) {
+			return args
+		}
+
+		var (
+			start = i
+			value string
+			n     int
+			ok    bool
+		)
+
+		if strings.HasPrefix(s[i:], "```") {
+			value, n, ok = readCodeBlock(s[i:])
+		} else if s[i] == '"' || s[i] == '\'' {
+			value, n, ok = readQuoted(s[i:])
+		}
+		if !ok {
+			value, n = readWord(s[i:])
+		}
+
+		i += n
+		args = append(args, Token{Value: value, Raw: s[start:]})
+	}
+}
+
+func leadingSpace(s string) int {
+	i := 0
+	for i < len(s) {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += n
+	}
+
+	return i
+}
+
+// readEscape returns the rune escaped by the backslash at the start of s. ok is
+// false if the backslash does not escape anything and should be kept.
+func readEscape(s string) (r rune, n int, ok bool) {
+	if len(s) < 2 {
+		return 0, 0, false
+	}
+
+	r, n = utf8.DecodeRuneInString(s[1:])
+	if r == '"' || r == '\'' || r == '\\' || unicode.IsSpace(r) {
+		return r, n + 1, true
+	}
+
+	return 0, 0, false
+}
+
+func readWord(s string) (value string, n int) {
+	var b strings.Builder
+
+	for n < len(s) {
+		if s[n] == '\\' {
+			if r, size, ok := readEscape(s[n:]); ok {
+				b.WriteRune(r)
+				n += size
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if unicode.IsSpace(r) {
+			break
+		}
+
+		b.WriteRune(r)
+		n += size
+	}
+
+	return b.String(), n
+}
+
+// readQuoted reads a string surrounded by the quote at the start of s. ok is
+// false if the quote is never closed.
+func readQuoted(s string) (value string, n int, ok bool) {
+	var (
+		b     strings.Builder
+		quote = s[0]
+	)
+
+	for n = 1; n < len(s); {
+		switch s[n] {
+		case quote:
+			return b.String(), n + 1, true
+
+		case '\\':
+			if r, size, ok := readEscape(s[n:]); ok {
+				b.WriteRune(r)
+				n += size
+				continue
+			}
+		}
+
+		b.WriteByte(s[n])
+		n++
+	}
+
+	return "", 0, false
+}
+
+// readCodeBlock reads a ```code block``` at the start of s, stripping the
+// fences and language identifier. ok is false if the block is never closed.
+func readCodeBlock(s string) (value string, n int, ok bool) {
+	end := strings.Index(s[3:], "```")
+	if end < 0 {
+		return "", 0, false
+	}
+
+	value = s[3 : 3+end]
+	if nl := strings.IndexByte(value, '\n'); nl >= 0 && !strings.ContainsAny(value[:nl], " \t") {
+		value = value[nl+1:]
+	}
+
+	return strings.Trim(value, "\n"), end + 6, true
+}
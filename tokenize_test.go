@@ -0,0 +1,52 @@
+package rikka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  a  b\tc\n", []string{"a", "b", "c"}},
+		{`ban @thy "being rude" 'and loud'`, []string{"ban", "@thy", "being rude", "and loud"}},
+		{`say "she said \"hi\""`, []string{"say", `she said "hi"`}},
+		{`hello\ world \\o/`, []string{"hello world", `\o/`}},
+		{`match ^\d+$`, []string{"match", `^\d+$`}},
+		{`don't stop`, []string{"don't", "stop"}},
+		{`"unterminated quote`, []string{`"unterminated`, "quote"}},
+		{"eval ```go\nfmt.Println(\"a b\")\n```", []string{"eval", `fmt.Println("a b")`}},
+		{"eval ```1 + 2```", []string{"eval", "1 + 2"}},
+		{"```open code", []string{"```open", "code"}},
+	} {
+		got := Tokenize(tc.in).Strings()
+		if len(got) == 0 && len(tc.want) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestArgsRest(t *testing.T) {
+	args := Tokenize("tag create greeting  Hello   there,\n friend")
+	args.Pop()
+	args.Pop()
+	args.Pop()
+
+	const want = "Hello   there,\n friend"
+	if got := args.Rest(); got != want {
+		t.Errorf("Rest() = %q, want %q", got, want)
+	}
+
+	args = Tokenize(`reason "quoted reason"`)
+	args.Pop()
+	if got := args.Rest(); got != "quoted reason" {
+		t.Errorf("Rest() = %q, want %q", got, "quoted reason")
+	}
+}